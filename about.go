@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var featuresFlag bool
+
+// A feature is a subsystem that can be left out of the binary with a build
+// tag.
+type feature struct {
+	name     string
+	tag      string
+	commands string
+	enabled  bool
+}
+
+// exerciseCommands are the commands that the cipheronly tag leaves out.
+var exerciseCommands = []string{"frequency", "demo", "bigrams", "weather", "grid", "simulate", "crib"}
+
+var features = []feature{
+	{"cipher", "", "crypt, verify, verify-archive, setup, components, seal, unseal", true},
+	{"exercises", "cipheronly", strings.Join(exerciseCommands, ", "), withExercises},
+}
+
+func about(cmd *cobra.Command, args []string) {
+	fmt.Printf("enigma, built with %v for %v/%v.\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Println("It collects no telemetry and never connects to the network.")
+	if !featuresFlag {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FEATURE\tINCLUDED\tCOMMANDS")
+	for _, f := range features {
+		included := "yes"
+		if f.tag != "" && f.enabled {
+			included += fmt.Sprintf(" (leave out with -tags=%v)", f.tag)
+		} else if f.tag != "" {
+			included = fmt.Sprintf("no (built with -tags=%v)", f.tag)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", f.name, included, f.commands)
+	}
+	w.Flush()
+}
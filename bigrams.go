@@ -0,0 +1,48 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var checkFileFlag string
+
+func bigrams(cmd *cobra.Command, args []string) {
+	setUpLogging()
+
+	// Check an existing table, if requested.
+	if checkFileFlag != "" {
+		f, err := os.Open(checkFileFlag)
+		if err != nil {
+			glog.Fatalf("Could not read %v: %s", checkFileFlag, err)
+		}
+		defer f.Close()
+		table, err := enigma.ReadBigramTable(f)
+		if err != nil {
+			glog.Fatalf("Could not read %v: %s", checkFileFlag, err)
+		}
+		if err := enigma.ValidateBigramTable(table); err != nil {
+			glog.Fatalf("%v: %s", checkFileFlag, err)
+		}
+		fmt.Printf("%v is a complete bigram table\n", checkFileFlag)
+		return
+	}
+
+	seed := seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("# Practice bigram table, generated with --seed=%v\n", seed)
+	table := enigma.GenerateBigramTable(rand.New(rand.NewSource(seed)))
+	if err := enigma.WriteBigramTable(os.Stdout, table); err != nil {
+		glog.Fatalf("Could not write bigram table: %s", err)
+	}
+}
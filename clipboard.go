@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardTool is an external program pair that can write to and read from
+// the system clipboard. Go has no portable clipboard API, so we shell out to
+// whatever the platform provides.
+type clipboardTool struct {
+	copy  []string
+	paste []string
+}
+
+// clipboardTools lists the known clipboard tools per operating system, in
+// order of preference.
+var clipboardTools = map[string][]clipboardTool{
+	"darwin": {
+		{copy: []string{"pbcopy"}, paste: []string{"pbpaste"}},
+	},
+	"windows": {
+		{copy: []string{"clip"}, paste: []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}},
+	},
+	"linux": {
+		{copy: []string{"wl-copy"}, paste: []string{"wl-paste", "--no-newline"}},
+		{copy: []string{"xclip", "-selection", "clipboard"}, paste: []string{"xclip", "-selection", "clipboard", "-o"}},
+		{copy: []string{"xsel", "--clipboard", "--input"}, paste: []string{"xsel", "--clipboard", "--output"}},
+	},
+}
+
+// findClipboardTool returns the first clipboard tool for this platform that is
+// installed, or an error if there is none.
+func findClipboardTool() (*clipboardTool, error) {
+	for _, tool := range clipboardTools[runtime.GOOS] {
+		if _, err := exec.LookPath(tool.copy[0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(tool.paste[0]); err != nil {
+			continue
+		}
+		return &tool, nil
+	}
+	return nil, fmt.Errorf("no clipboard tool found for %v", runtime.GOOS)
+}
+
+// writeClipboard replaces the contents of the system clipboard with `text`.
+func writeClipboard(text string) error {
+	tool, err := findClipboardTool()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(tool.copy[0], tool.copy[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not write to clipboard with %v: %v", tool.copy[0], err)
+	}
+	return nil
+}
+
+// readClipboard returns the current contents of the system clipboard.
+func readClipboard() (string, error) {
+	tool, err := findClipboardTool()
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(tool.paste[0], tool.paste[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read from clipboard with %v: %v", tool.paste[0], err)
+	}
+	return string(out), nil
+}
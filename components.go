@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var componentsModelFlag string
+
+// yearString formats the year a component was introduced, where 0 means it
+// is a custom component.
+func yearString(year int) string {
+	if year == 0 {
+		return "-"
+	}
+	return fmt.Sprint(year)
+}
+
+func components(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	loadComponentFile()
+	rotors, err := enigma.ListRotors(componentsModelFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	reflectors, err := enigma.ListReflectors(componentsModelFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Rotor\tFamily\tSince\tWiring\tNotches")
+	for _, r := range rotors {
+		notches := r.Notches
+		if notches == "" {
+			notches = "none"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", r.Name, r.Family, yearString(r.Introduced), r.Wiring, notches)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Reflector\tFamily\tSince\tWiring")
+	for _, r := range reflectors {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", r.Name, r.Family, yearString(r.Introduced), r.Wiring)
+	}
+	w.Flush()
+}
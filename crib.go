@@ -0,0 +1,72 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var cribFlag string
+var offsetFlag int
+var menuDOTFlag bool
+
+// alignedCrib returns `crib` indented to lie at `offset`, with the letters
+// that clash in lowercase.
+func alignedCrib(crib string, offset int, clashes []int) string {
+	b := []byte(crib)
+	for _, i := range clashes {
+		b[i] = b[i] - 'A' + 'a'
+	}
+	return strings.Repeat(" ", offset) + string(b)
+}
+
+func crib(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	ciphertext := strings.ToUpper(strings.Join(strings.Fields(strings.Join(args, " ")), ""))
+	crib := strings.ToUpper(strings.Join(strings.Fields(cribFlag), ""))
+	if strings.Trim(ciphertext+crib, string(enigma.Letters)) != "" {
+		glog.Fatalf("The ciphertext and --crib must be letters A-Z")
+	}
+	if crib == "" || len(crib) > len(ciphertext) {
+		glog.Fatalf("--crib must be given, and no longer than the ciphertext")
+	}
+
+	if offsetFlag < 0 {
+		fmt.Printf("  %v\n", ciphertext)
+		positions := enigma.CribPositions(ciphertext, crib)
+		for offset := 0; offset+len(crib) <= len(ciphertext); offset++ {
+			clashes := enigma.CribClashes(ciphertext, crib, offset)
+			status := fmt.Sprintf("%3d  possible", offset)
+			if len(clashes) > 0 {
+				status = fmt.Sprintf("%3d  %v clash", offset, len(clashes))
+			}
+			fmt.Printf("  %-*v  %v\n", len(ciphertext), alignedCrib(crib, offset, clashes), status)
+		}
+		fmt.Printf("\n%v of %v offsets remain: %v. Clashing letters are in lowercase.\n",
+			len(positions), len(ciphertext)-len(crib)+1, positions)
+		fmt.Println("Use --offset to see the menu for one of them.")
+		return
+	}
+
+	menu, err := enigma.CribMenu(ciphertext, crib, offsetFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	if menuDOTFlag {
+		if err := enigma.WriteMenuDOT(os.Stdout, menu); err != nil {
+			glog.Fatalf("Could not write the menu: %s", err)
+		}
+		return
+	}
+	fmt.Printf("  %v\n  %v\n\nMenu:\n", ciphertext, alignedCrib(crib, offsetFlag, nil))
+	for _, link := range menu {
+		fmt.Printf("  %3d  %c-%c\n", link.Position, link.Plain, link.Cipher)
+	}
+	fmt.Printf("\nLetters, best connected first: %s\n", enigma.MenuLetters(menu))
+}
@@ -0,0 +1,148 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var trialsFlag int
+var seedFlag int64
+
+// The number of letters typed per random key.
+const demoMessageLength = 100
+
+// The crib used to show how the lack of self-encryption rules out positions.
+const demoCrib = "WETTERBERICHT"
+
+// randomLetters returns `n` random letters.
+func randomLetters(rnd *rand.Rand, n int) []byte {
+	letters := make([]byte, n)
+	for i := range letters {
+		letters[i] = byte('A' + rnd.Intn(26))
+	}
+	return letters
+}
+
+// randomKey returns a description of a random Enigma I key, and a function
+// that sets up a machine with it. Calling the function again resets the
+// machine to the start of the key.
+func randomKey(rnd *rand.Rand) (string, func() enigma.Enigma) {
+	names := []string{"I", "II", "III", "IV", "V"}
+	rnd.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+	names = names[:3]
+	reflector := []string{"B", "C"}[rnd.Intn(2)]
+	ringSettings := randomLetters(rnd, 3)
+	positions := randomLetters(rnd, 3)
+	alphabet := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	rnd.Shuffle(len(alphabet), func(i, j int) { alphabet[i], alphabet[j] = alphabet[j], alphabet[i] })
+	var pairs []string
+	var plugboard enigma.Plugboard
+	for i := 0; i < 20; i += 2 {
+		pairs = append(pairs, string(alphabet[i:i+2]))
+		plugboard.AddPlugPair(alphabet[i], alphabet[i+1])
+	}
+
+	description := fmt.Sprintf("--reflector=%v --rotors=%v --ringSettings=%s --plugPairs=%v --positions=%s",
+		reflector, strings.Join(names, ","), strings.Join(strings.Split(string(ringSettings), ""), ","),
+		strings.Join(pairs, ","), strings.Join(strings.Split(string(positions), ""), ","))
+	e := enigma.New()
+	e.InstallReflector(enigma.Reflectors[reflector])
+	rotors := make([]enigma.Rotor, len(names))
+	for i, name := range names {
+		rotors[i] = enigma.Rotors[name]
+	}
+	// The settings are all chosen to fit, so these can't fail.
+	e.InstallRotors(rotors)
+	e.SetRingSettings(ringSettings)
+	e.SetPlugboard(plugboard)
+	config, _ := enigma.NewConfig(e) // Nor can this, for a machine from NewModel.
+	return description, func() enigma.Enigma {
+		e, _ := config.NewMachine(positions)
+		return e
+	}
+}
+
+// newDemoRand returns the random source for a demo, seeded from --seed if
+// given, so that runs can be repeated.
+func newDemoRand() *rand.Rand {
+	seed := seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Using random seed %v.\n", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+func demoReciprocity(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	rnd := newDemoRand()
+	for trial := 0; trial < trialsFlag; trial++ {
+		key, setUp := randomKey(rnd)
+		plain := string(randomLetters(rnd, demoMessageLength))
+		cipher := enigma.Type(setUp(), plain)
+		if decrypted := enigma.Type(setUp(), cipher); decrypted != plain {
+			glog.Fatalf("Reciprocity failed for key %v: %v encrypts to %v, which decrypts to %v",
+				key, plain, cipher, decrypted)
+		}
+	}
+	fmt.Printf(`Typed %v random messages of %v letters, each with a different random key, and typed
+each ciphertext again with the same key: every one came back as its plaintext.
+
+The Enigma is reciprocal: at any rotor position, if A lights up B, then B lights up A. The
+current enters through the rotors, bounces off the reflector, which swaps letters in pairs, and
+comes back the same way. Because of this, encrypting and decrypting are the same operation, which
+is why there is only one 'crypt' command.
+`, trialsFlag, demoMessageLength)
+}
+
+func demoNoSelfMap(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	rnd := newDemoRand()
+	var key string
+	var setUp func() enigma.Enigma
+	for trial := 0; trial < trialsFlag; trial++ {
+		key, setUp = randomKey(rnd)
+		plain := randomLetters(rnd, demoMessageLength)
+		cipher := enigma.Type(setUp(), string(plain))
+		for i := range plain {
+			if plain[i] == cipher[i] {
+				glog.Fatalf("Letter %v of %s encrypted to itself with key %v", i+1, plain, key)
+			}
+		}
+	}
+	fmt.Printf(`Typed %v random messages of %v letters, each with a different random key: not one
+of the %v letters encrypted to itself.
+
+The reflector never connects a contact to itself, so the current can never return on the wire
+it came in on. This is what made crib attacks practical: if you suspect that a message contains
+a word (a "crib"), it can't be at any position where one of its letters lines up with the same
+letter in the ciphertext.
+
+For example, here is the crib %v in a message typed with the last key:
+  %v
+`, trialsFlag, demoMessageLength, trialsFlag*demoMessageLength, demoCrib, key)
+
+	plain := string(randomLetters(rnd, 10)) + demoCrib + string(randomLetters(rnd, 10))
+	cipher := enigma.Type(setUp(), plain)
+	fmt.Printf("\n  plaintext:  %v\n  ciphertext: %v\n\n", plain, cipher)
+	possible := 0
+	for offset := 0; offset+len(demoCrib) <= len(cipher); offset++ {
+		clashes := enigma.CribClashes(cipher, demoCrib, offset)
+		status := "possible"
+		if len(clashes) > 0 {
+			status = fmt.Sprintf("ruled out: %c would encrypt to itself", demoCrib[clashes[0]])
+		} else {
+			possible++
+		}
+		fmt.Printf("  %-*v  %v\n", 12+len(cipher), strings.Repeat(" ", offset+12)+demoCrib, status)
+	}
+	fmt.Printf("\nOnly %v of %v positions remain to be tried.\n", possible, len(cipher)-len(demoCrib)+1)
+}
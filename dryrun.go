@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var dryRunFlag bool
+
+// A setting is one field of the effective machine configuration, and where
+// its value came from.
+type setting struct {
+	name, value, source string
+}
+
+// settingSource returns where the value of the machine flag `flag` came
+// from: --settings (or --settingsFile), which overrides the individual flags,
+// the flag itself, or its default.
+func settingSource(cmd *cobra.Command, flag string, inSettings bool) string {
+	switch {
+	case inSettings && settingsFromFile, flag == "model" && modelFromFile:
+		return "--settingsFile"
+	case inSettings && settingsFlag != "":
+		return "--settings"
+	case cmd.Flags().Changed(flag):
+		return "--" + flag
+	}
+	return "default"
+}
+
+// configFlags are the machine flags that set each field of an enigma.Config.
+var configFlags = map[string]string{
+	enigma.ReflectorField:         "reflector",
+	enigma.RotorsField:            "rotors",
+	enigma.RingSettingsField:      "ringSettings",
+	enigma.PlugPairsField:         "plugPairs",
+	enigma.ReflectorPositionField: "reflectorPosition",
+}
+
+// effectiveConfig returns the setup of `e`, as set up by setUpEnigma from
+// the flags of `cmd`, recording where each field came from.
+func effectiveConfig(cmd *cobra.Command, e enigma.Enigma) enigma.Config {
+	config, err := enigma.NewConfig(e)
+	if err != nil {
+		glog.Fatalf("Could not read the machine's settings: %s", err)
+	}
+	for _, field := range enigma.ConfigFields {
+		config = config.WithSource(field, settingSource(cmd, configFlags[field], true))
+	}
+	return config
+}
+
+// effectiveSettings lists the settings of `e`, as set up by setUpEnigma from
+// the flags of `cmd`.
+func effectiveSettings(cmd *cobra.Command, e enigma.Enigma) []setting {
+	config := effectiveConfig(cmd, e)
+	state, err := config.State()
+	if err != nil {
+		glog.Fatalf("Could not read the machine's settings: %s", err)
+	}
+	var rotors []string
+	for _, rotor := range state.Rotors {
+		rotors = append(rotors, rotor.String())
+	}
+	values := map[string]string{
+		enigma.ReflectorField:         state.Reflector.String(),
+		enigma.RotorsField:            strings.Join(rotors, " "),
+		enigma.RingSettingsField:      state.RingSettings,
+		enigma.PlugPairsField:         strings.Join(state.PlugPairs, " "),
+		enigma.ReflectorPositionField: state.ReflectorPosition,
+	}
+	settings := []setting{{"model", modelFlag, settingSource(cmd, "model", false)}}
+	for _, field := range enigma.ConfigFields {
+		value := values[field]
+		if value == "" {
+			value = "-"
+		}
+		settings = append(settings, setting{field, value, config.Source(field)})
+	}
+	// The positions aren't part of a Config, since they change as the
+	// machine is typed on.
+	settings = append(settings,
+		setting{"positions", state.Positions, settingSource(cmd, "positions", true)})
+	if componentFileFlag != "" {
+		settings = append(settings, setting{"components", componentFileFlag, "--componentFile"})
+	}
+	for _, definition := range customRotorsFlag {
+		settings = append(settings, setting{"custom rotor", definition, "--customRotor"})
+	}
+	for _, definition := range customReflectorsFlag {
+		settings = append(settings, setting{"custom reflector", definition, "--customReflector"})
+	}
+	return settings
+}
+
+// printDryRun prints the machine that `cmd` would type on, and where each of
+// its settings came from, instead of typing anything.
+func printDryRun(cmd *cobra.Command, e enigma.Enigma) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tFROM")
+	for _, s := range effectiveSettings(cmd, e) {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", s.name, s.value, s.source)
+	}
+	w.Flush()
+	state, _ := e.SaveState() // Already checked by effectiveSettings.
+	fmt.Printf("\nAs --settings: %v\n", state)
+}
@@ -0,0 +1,81 @@
+package enigma
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxContacts is the most contacts that any component can have, and so the
+// largest alphabet a machine can have.
+const maxContacts = 64
+
+// An Alphabet holds the symbols on an Enigma's keys, in the order of the
+// contacts they connect to. Its ring settings and rotor positions are written
+// in the same symbols. Symbols are single printable ASCII characters other
+// than space, which Type passes through for readability.
+type Alphabet string
+
+// The alphabets of the historical machines, and a larger one for teaching.
+const (
+	// Letters are the keys of most Enigmas.
+	Letters Alphabet = letters
+	// Digits are the keys of the Enigma Z.
+	Digits Alphabet = digits
+	// Alphanumeric is the letters A-Z followed by the digits 0-9, so that
+	// numbers can be enciphered without spelling them out.
+	Alphanumeric Alphabet = letters + "0123456789"
+)
+
+// NewAlphabet returns the alphabet of the given symbols, in order, or an error
+// if they can't label an Enigma's keys. There must be an even number of them,
+// as a reflector pairs them up, and at most 64.
+func NewAlphabet(symbols string) (Alphabet, error) {
+	if err := Alphabet(symbols).check(); err != nil {
+		return "", err
+	}
+	return Alphabet(symbols), nil
+}
+
+// check returns an error if the alphabet can't label an Enigma's keys (see
+// NewAlphabet). Alphabets converted from strings, or read from JSON, haven't
+// been checked, and must be before they size a component.
+func (a Alphabet) check() error {
+	if len(a) < 2 || len(a) > maxContacts || len(a)%2 != 0 {
+		return fmt.Errorf(
+			"alphabets must have an even number of symbols from 2 to %v, got %v", maxContacts, len(a))
+	}
+	for i := 0; i < len(a); i++ {
+		s := a[i]
+		if s <= ' ' || s > '~' {
+			return fmt.Errorf("%q at position %v of %q is not a printable ASCII character", s, i+1, string(a))
+		}
+		if strings.IndexByte(string(a[:i]), s) >= 0 {
+			return fmt.Errorf("%q appears twice in %q", s, string(a))
+		}
+	}
+	return nil
+}
+
+// Len returns the number of symbols in the alphabet, which is the number of
+// contacts on the components of a machine that uses it.
+func (a Alphabet) Len() int {
+	return len(a)
+}
+
+// Index returns the number of `symbol` in the alphabet, counting from 0, or
+// -1 if it isn't in the alphabet.
+func (a Alphabet) Index(symbol byte) int {
+	// The classic alphabet is by far the most common, and needs no search.
+	if a == Letters {
+		if symbol < 'A' || symbol > 'Z' {
+			return -1
+		}
+		return int(symbol - 'A')
+	}
+	return strings.IndexByte(string(a), symbol)
+}
+
+// Contains returns whether `symbol` is in the alphabet.
+func (a Alphabet) Contains(symbol byte) bool {
+	return a.Index(symbol) >= 0
+}
@@ -0,0 +1,114 @@
+package enigma
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Archive files hold an archive in JSON, one message per line, along with the
+// model and the name of the key sheet each was sent with. For example:
+//
+//	{"model": "I", "keySheet": "1941-05-01", "from": "KOELN", "to": "WIEN", "sent": "1941-05-01T08:00:00Z", "messageKey": "QRS", "plaintext": "ANXWIEN", "ciphertext": "KXBZWLP"}
+//
+// Every field is optional. The key sheets are kept apart, e.g. in a
+// KeySheetDir.
+
+// An ArchiveRecord is a message in an archive file.
+type ArchiveRecord struct {
+	Transmission
+
+	// The model of Enigma the message was sent with (see Models), and the name
+	// of its key sheet.
+	Model, KeySheet string
+}
+
+// archiveLine is the JSON form of an ArchiveRecord.
+type archiveLine struct {
+	Model      string    `json:"model,omitempty"`
+	KeySheet   string    `json:"keySheet,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to,omitempty"`
+	Sent       time.Time `json:"sent"`
+	Received   time.Time `json:"received"`
+	Indicator  string    `json:"indicator,omitempty"`
+	Ciphertext string    `json:"ciphertext,omitempty"`
+	MessageKey string    `json:"messageKey,omitempty"`
+	Plaintext  string    `json:"plaintext,omitempty"`
+	Decrypted  string    `json:"decrypted,omitempty"`
+}
+
+// ReadArchiveJSON reads an archive file in the format above.
+func ReadArchiveJSON(r io.Reader) ([]ArchiveRecord, error) {
+	var records []ArchiveRecord
+	scanner := bufio.NewScanner(r)
+	// Long messages make for long lines.
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l archiveLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		records = append(records, ArchiveRecord{
+			Transmission: Transmission{
+				From: l.From, To: l.To, Sent: l.Sent, Received: l.Received,
+				Indicator: l.Indicator, Ciphertext: l.Ciphertext,
+				MessageKey: l.MessageKey, Plaintext: l.Plaintext, Decrypted: l.Decrypted,
+				Reference: l.Reference,
+			},
+			Model:    l.Model,
+			KeySheet: l.KeySheet,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// WriteArchiveJSON writes `records` in the archive file format above.
+func WriteArchiveJSON(w io.Writer, records []ArchiveRecord) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		err := encoder.Encode(archiveLine{
+			Model: r.Model, KeySheet: r.KeySheet, Reference: r.Reference,
+			From: r.From, To: r.To, Sent: r.Sent, Received: r.Received,
+			Indicator: r.Indicator, Ciphertext: r.Ciphertext,
+			MessageKey: r.MessageKey, Plaintext: r.Plaintext, Decrypted: r.Decrypted,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verifiable returns whether enough is known about the message to check its
+// ciphertext: its key sheet, message key, plaintext and ciphertext.
+func (r ArchiveRecord) Verifiable() bool {
+	return r.KeySheet != "" && r.MessageKey != "" && r.Plaintext != "" && r.Ciphertext != ""
+}
+
+// Reencrypt encrypts the message's plaintext again, with `key` as its key
+// sheet, at its message key. If the archive is right, the result is its
+// ciphertext. The model defaults to the Enigma I.
+func (r ArchiveRecord) Reencrypt(key KeySheet) (string, error) {
+	model := r.Model
+	if model == "" {
+		model = "I"
+	}
+	e, err := key.NewMachine(model)
+	if err != nil {
+		return "", err
+	}
+	if err := e.SetRotorPositions([]byte(r.MessageKey)); err != nil {
+		return "", fmt.Errorf("message key %q: %w", r.MessageKey, err)
+	}
+	return Type(e, r.Plaintext), nil
+}
@@ -0,0 +1,104 @@
+package enigma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Bigram table files hold one or more pairs per line, written like "AB=XY"
+// and separated by whitespace. Everything after a '#' on a line is a comment.
+// For example:
+//
+//	# Practice table 3
+//	AA=KQ AB=ZU AC=MF
+//	AD=BR ...
+
+// The number of pairs per line in the files written by WriteBigramTable.
+const bigramPairsPerLine = 8
+
+// ReadBigramTable reads a bigram table in the file format above. The table may
+// be incomplete; use ValidateBigramTable to check that it covers every bigram.
+func ReadBigramTable(r io.Reader) (*BigramTable, error) {
+	var table BigramTable
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		for _, pair := range strings.Fields(text) {
+			parts := strings.Split(pair, "=")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("line %v: %q is not a pair like 'AB=XY'", line, pair)
+			}
+			if err := table.AddPair(parts[0], parts[1]); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// WriteBigramTable writes `table` in the file format above, with every pair
+// once, in alphabetical order.
+func WriteBigramTable(w io.Writer, table *BigramTable) error {
+	var lefts []string
+	for left, right := range table.mapping {
+		if left < right {
+			lefts = append(lefts, left)
+		}
+	}
+	sort.Strings(lefts)
+	for i, left := range lefts {
+		separator := " "
+		if (i+1)%bigramPairsPerLine == 0 || i == len(lefts)-1 {
+			separator = "\n"
+		}
+		if _, err := fmt.Fprintf(w, "%v=%v%v", left, table.mapping[left], separator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allBigrams returns every bigram AA-ZZ, in alphabetical order.
+func allBigrams() []string {
+	bigrams := make([]string, 0, int(numLetters)*int(numLetters))
+	for i := 0; i < int(numLetters); i++ {
+		for j := 0; j < int(numLetters); j++ {
+			bigrams = append(bigrams, string([]byte{letters[i], letters[j]}))
+		}
+	}
+	return bigrams
+}
+
+// ValidateBigramTable returns `nil` if `table` maps every bigram AA-ZZ, as the
+// historical tables did, or an error naming the first bigram it misses.
+func ValidateBigramTable(table *BigramTable) error {
+	for _, bigram := range allBigrams() {
+		if _, ok := table.mapping[bigram]; !ok {
+			return fmt.Errorf("invalid bigram table: bigram %q is missing", bigram)
+		}
+	}
+	return nil
+}
+
+// GenerateBigramTable creates a complete, random bigram table, for practice
+// when the historical tables aren't available.
+func GenerateBigramTable(rnd *rand.Rand) *BigramTable {
+	bigrams := allBigrams()
+	rnd.Shuffle(len(bigrams), func(i, j int) { bigrams[i], bigrams[j] = bigrams[j], bigrams[i] })
+	var table BigramTable
+	for i := 0; i < len(bigrams); i += 2 {
+		// Distinct bigrams that haven't been paired yet always make a valid pair.
+		table.AddPair(bigrams[i], bigrams[i+1])
+	}
+	return &table
+}
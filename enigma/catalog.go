@@ -0,0 +1,192 @@
+package enigma
+
+import "fmt"
+
+// RotorInfo describes a historical rotor in the catalog, from which Rotors
+// is built.
+type RotorInfo struct {
+	// The rotor's name in Rotors, e.g. "IV".
+	Name string
+	// The machines the rotor was issued for, e.g. "Enigma I".
+	Family string
+	// The year the rotor was introduced, as far as it is known; for some
+	// rotors it is approximate. It is 0 for custom rotors.
+	Introduced int
+	// The rotor's wiring, in the format of MakeRotor.
+	Wiring string
+	// The rotor's turnover points, if any.
+	Notches string
+	// Whether this is a thin rotor (see Rotor.Thin).
+	Thin bool
+}
+
+// ReflectorInfo describes a historical reflector in the catalog, from which
+// Reflectors is built.
+type ReflectorInfo struct {
+	// The reflector's name in Reflectors, e.g. "B".
+	Name string
+	// The machines the reflector was issued for, e.g. "Enigma I".
+	Family string
+	// The year the reflector was introduced, as far as it is known; for some
+	// reflectors it is approximate. It is 0 for custom reflectors.
+	Introduced int
+	// The reflector's wiring, written like a rotor's (see MakeRotor).
+	Wiring string
+	// Whether this is a thin reflector (see Reflector.Thin).
+	Thin bool
+}
+
+// rotorCatalog lists the historical rotors. The wirings of the Enigma Z's
+// rotors use digits instead of letters.
+var rotorCatalog = []RotorInfo{
+	{"I", "Enigma I", 1930, "EKMFLGDQVZNTOWYHXUSPAIBRCJ", "Q", false},
+	{"II", "Enigma I", 1930, "AJDKSIRUXBLHWTMCQGZNPYFVOE", "E", false},
+	{"III", "Enigma I", 1930, "BDFHJLCPRTXVZNYEIWGAKMUSQO", "V", false},
+	{"IV", "Enigma I", 1938, "ESOVPZJAYQUIRHXLNFTGKDCMWB", "J", false},
+	{"V", "Enigma I", 1938, "VZBRGITYUPSDNHLXAWMJQOFECK", "Z", false},
+	{"VI", "Enigma M3 and M4", 1939, "JPGVOUMFYQBENHZRDKASXLICTW", "ZM", false},
+	{"VII", "Enigma M3 and M4", 1940, "NZJHGRCXMYSWBOUFAIVLPEKQDT", "ZM", false},
+	{"VIII", "Enigma M3 and M4", 1940, "FKQHTLXOCBJSPDZRAMEWNIUYGV", "ZM", false},
+	{"Beta", "Enigma M4", 1942, "LEYJVCNIXWPBQMDRTAKZGFUHOS", "", true},
+	{"Gamma", "Enigma M4", 1943, "FSOKANUERHMBTIYCWLQPZXVGJD", "", true},
+	{"G-I", "Enigma G", 1931, "DMTWSILRUYQNKFEJCAZBPGXOHV", "SUVWZABCEFGIKLOPQ", false},
+	{"G-II", "Enigma G", 1931, "HQZGPJTMOBLNCIFDYAWVEUSRKX", "STVYZACDFGHKMNQ", false},
+	{"G-III", "Enigma G", 1931, "UQNTLSZFMREHDPXKIBVYGJCWOA", "UWXAEFHKMNR", false},
+	{"K-I", "Enigma K", 1927, "LPGSZMHAEOQKVXRFYBUTNICJDW", "Y", false},
+	{"K-II", "Enigma K", 1927, "SLVGBTFXJQOHEWIRZYAMKPCNDU", "E", false},
+	{"K-III", "Enigma K", 1927, "CJGDPSHKTURAWZXFMYNQOBVLIE", "N", false},
+	{"SK-I", "Swiss Enigma K", 1939, "PEZUOHXSCVFMTBGLRINQJWAYDK", "Y", false},
+	{"SK-II", "Swiss Enigma K", 1939, "ZOUESYDKFWPCIQXHMVBLGNJRAT", "E", false},
+	{"SK-III", "Swiss Enigma K", 1939, "EHRVXGAOBQUSIMZFLYNWKTPDJC", "N", false},
+	{"T-I", "Enigma T", 1942, "KPTYUELOCVGRFQDANJMBSWHZXI", "WZEKQ", false},
+	{"T-II", "Enigma T", 1942, "UPHZLWEQMTDJXCAKSOIGVBYFNR", "WZFLR", false},
+	{"T-III", "Enigma T", 1942, "QUDLYRFEKONVZAXWHMGPJBSICT", "WZEKQ", false},
+	{"T-IV", "Enigma T", 1942, "CIWTBKXNRESPFLYDAGVHQUOJZM", "WZFLR", false},
+	{"T-V", "Enigma T", 1942, "UAXGISNJBVERDYLFZWTPCKOHMQ", "YCFKR", false},
+	{"T-VI", "Enigma T", 1942, "XFUZGALVHCNYSEWQTDMRBKPIOJ", "XEIMQ", false},
+	{"T-VII", "Enigma T", 1942, "BJVFTXPLNAYOZIKWGDQERUCHSM", "YCFKR", false},
+	{"T-VIII", "Enigma T", 1942, "YMTPNZHWKODAJXELUQVGCBISFR", "XEIMQ", false},
+	{"N-I", "Norenigma", 1945, "WTOKASUYVRBXJHQCPZEFMDINLG", "Q", false},
+	{"N-II", "Norenigma", 1945, "GJLPUBSWEMCTQVHXAOFZDRKYNI", "E", false},
+	{"N-III", "Norenigma", 1945, "JWFMHNBPUSDYTIXVZGRQLAOEKC", "V", false},
+	{"N-IV", "Norenigma", 1945, "FGZJMVXEPBWSHQTLIUDYKCNRAO", "J", false},
+	{"N-V", "Norenigma", 1945, "HEJXQOTZBVFDASCILWPGYNMURK", "Z", false},
+	{"Z-I", "Enigma Z", 1931, "6418270359", "9", false},
+	{"Z-II", "Enigma Z", 1931, "5841097632", "9", false},
+	{"Z-III", "Enigma Z", 1931, "3581620794", "9", false},
+}
+
+// reflectorCatalog lists the historical reflectors, like rotorCatalog.
+var reflectorCatalog = []ReflectorInfo{
+	{"A", "Enigma I", 1930, "EJMZALYXVBWFCRQUONTSPIKHGD", false},
+	{"B", "Enigma I, M3", 1937, "YRUHQSLDPXNGOKMIEBFZCWVJAT", false},
+	{"C", "Enigma I, M3", 1940, "FVPJIAOYEDRZXWGCTKUQSBNMHL", false},
+	{"B-thin", "Enigma M4", 1942, "ENKQAUYWJICOPBLMDXZVFTHRGS", true},
+	{"C-thin", "Enigma M4", 1943, "RDOBJNTKVEHMLFCWZAXGYIPSUQ", true},
+	{"G", "Enigma G", 1931, "RULQMZJSYGOCETKWDAHNBXPVIF", false},
+	{"K", "Enigma K", 1927, "IMETCGFRAYSQBZXWLHKDVUPOJN", false},
+	{"T", "Enigma T", 1942, "GEKPBTAUMOCNILJDXZYFHWVQSR", false},
+	{"N", "Norenigma", 1945, "MOWJYPUXNDSRAIBFVLKZGQCHET", false},
+	{"Z", "Enigma Z", 1931, "5079183642", false},
+}
+
+// catalogRotors builds Rotors from rotorCatalog.
+func catalogRotors() map[string]Rotor {
+	rotors := make(map[string]Rotor, len(rotorCatalog))
+	for _, info := range rotorCatalog {
+		switch {
+		case info.Thin:
+			rotors[info.Name] = mustMakeGreekRotor(info.Wiring)
+		case len(info.Wiring) == len(digits):
+			rotors[info.Name] = mustMakeDigitRotor(info.Wiring, info.Notches)
+		default:
+			rotors[info.Name] = mustMakeRotor(info.Wiring, info.Notches)
+		}
+	}
+	return rotors
+}
+
+// catalogReflectors builds Reflectors from reflectorCatalog.
+func catalogReflectors() map[string]Reflector {
+	reflectors := make(map[string]Reflector, len(reflectorCatalog))
+	for _, info := range reflectorCatalog {
+		switch {
+		case info.Thin:
+			reflectors[info.Name] = mustMakeThinReflector(info.Wiring)
+		case len(info.Wiring) == len(digits):
+			reflectors[info.Name] = mustMakeDigitReflector(info.Wiring)
+		default:
+			reflectors[info.Name] = mustMakeReflector(info.Wiring)
+		}
+	}
+	return reflectors
+}
+
+// LookupRotor returns the catalog entry of the rotor called `name`.
+func LookupRotor(name string) (RotorInfo, error) {
+	for _, info := range rotorCatalog {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return RotorInfo{}, fmt.Errorf("rotor %v does not exist; options are %v", name, RotorNames())
+}
+
+// LookupReflector returns the catalog entry of the reflector called `name`.
+func LookupReflector(name string) (ReflectorInfo, error) {
+	for _, info := range reflectorCatalog {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return ReflectorInfo{}, fmt.Errorf(
+		"reflector %v does not exist; options are %v", name, ReflectorNames())
+}
+
+// ListRotors returns the catalog entries of the rotors that fit the named
+// model (see Models), in the order the model lists them, followed by any
+// custom rotors that fit (see RegisterRotor). For an empty `model`, it
+// returns all rotors, sorted by name.
+func ListRotors(model string) ([]RotorInfo, error) {
+	names, err := modelComponents(model, RotorNames(), Model.fittingRotors)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RotorInfo, len(names))
+	for i, name := range names {
+		if infos[i], err = LookupRotor(name); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// ListReflectors returns the catalog entries of the reflectors that fit the
+// named model, like ListRotors.
+func ListReflectors(model string) ([]ReflectorInfo, error) {
+	names, err := modelComponents(model, ReflectorNames(), Model.fittingReflectors)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ReflectorInfo, len(names))
+	for i, name := range names {
+		if infos[i], err = LookupReflector(name); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// modelComponents returns the names of the components that fit the named
+// model, as picked from the model by `components`, or `all` (sorted) if
+// `model` is empty.
+func modelComponents(model string, all []string, components func(Model) []string) ([]string, error) {
+	if model == "" {
+		return all, nil
+	}
+	m, ok := Models[model]
+	if !ok {
+		return nil, fmt.Errorf("model %v does not exist; options are %v", model, ModelNames())
+	}
+	return components(m), nil
+}
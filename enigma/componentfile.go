@@ -0,0 +1,81 @@
+package enigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Component files define custom rotors and reflectors in JSON, with wirings
+// written as for MakeRotor and turnover points as a string of letters. For
+// example:
+//
+//	{
+//	  "rotors": [
+//	    {"name": "Museum-I", "wiring": "EKMFLGDQVZNTOWYHXUSPAIBRCJ", "notches": "Q"}
+//	  ],
+//	  "reflectors": [
+//	    {"name": "Museum-B", "wiring": "YRUHQSLDPXNGOKMIEBFZCWVJAT"}
+//	  ]
+//	}
+type componentFile struct {
+	Rotors []struct {
+		Name, Wiring, Notches string
+	}
+	Reflectors []struct {
+		Name, Wiring string
+	}
+}
+
+// LoadComponents reads custom rotors and reflectors in the file format above
+// and registers them (see RegisterRotor and RegisterReflector). All
+// components are checked before any is registered, so if an error is
+// returned, none are.
+func LoadComponents(r io.Reader) error {
+	var file componentFile
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("could not read components: %v", err)
+	}
+
+	rotorNames := make(map[string]bool)
+	for _, rotor := range file.Rotors {
+		if rotor.Name == "" {
+			return fmt.Errorf("rotor with wiring %v has no name", rotor.Wiring)
+		}
+		if _, exists := Rotors[rotor.Name]; exists || rotorNames[rotor.Name] {
+			return fmt.Errorf("rotor %v is defined twice", rotor.Name)
+		}
+		rotorNames[rotor.Name] = true
+		if _, err := MakeRotor(rotor.Wiring, []byte(rotor.Notches)...); err != nil {
+			return fmt.Errorf("rotor %v: %w", rotor.Name, err)
+		}
+	}
+	reflectorNames := make(map[string]bool)
+	for _, reflector := range file.Reflectors {
+		if reflector.Name == "" {
+			return fmt.Errorf("reflector with wiring %v has no name", reflector.Wiring)
+		}
+		if _, exists := Reflectors[reflector.Name]; exists || reflectorNames[reflector.Name] {
+			return fmt.Errorf("reflector %v is defined twice", reflector.Name)
+		}
+		reflectorNames[reflector.Name] = true
+		if _, err := makeReflector(letters, reflector.Wiring); err != nil {
+			return fmt.Errorf("reflector %v: %w", reflector.Name, err)
+		}
+	}
+
+	// Everything checks out, so registering can't fail.
+	for _, rotor := range file.Rotors {
+		if err := RegisterRotor(rotor.Name, rotor.Wiring, []byte(rotor.Notches)...); err != nil {
+			return err
+		}
+	}
+	for _, reflector := range file.Reflectors {
+		if err := RegisterReflector(reflector.Name, reflector.Wiring); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package enigma
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Config is how an Enigma is set up for the day: its model, reflector,
+// rotors, ring settings and plugboard, without the rotor positions that
+// change as it's typed on. A Config never changes, so it can be shared
+// freely, e.g. between goroutines, and NewMachine cheaply makes as many
+// independent machines from it as needed, each with its own positions. This
+// suits searches that try many positions for the same setup, and anything
+// else that would otherwise reset one shared machine over and over.
+//
+// A Config can also record where each of its fields came from, e.g. which
+// command-line flag or file, for showing users which setting won (see
+// WithSource).
+type Config struct {
+	// A machine with the setup, which nothing types on or changes.
+	prototype *enigma
+
+	// Where each field came from, by field. Never changed once set.
+	sources map[string]string
+}
+
+// The fields of a Config whose source can be recorded (see WithSource).
+const (
+	ReflectorField         = "reflector"
+	RotorsField            = "rotors"
+	RingSettingsField      = "ring settings"
+	PlugPairsField         = "plug pairs"
+	ReflectorPositionField = "reflector position"
+)
+
+// ConfigFields lists the fields of a Config, in the order they are set up.
+var ConfigFields = []string{
+	ReflectorField, RotorsField, RingSettingsField, PlugPairsField, ReflectorPositionField,
+}
+
+// NewConfig returns the current setup of `e` as a Config. Later changes to
+// `e` don't affect it. `e` must be a machine made by this package, or one
+// wrapped with Synchronized; other implementations of Enigma are an error.
+func NewConfig(e Enigma) (Config, error) {
+	switch e := e.(type) {
+	case *enigma:
+		return Config{prototype: e.Clone().(*enigma)}, nil
+	case *SynchronizedEnigma:
+		var c Config
+		var err error
+		e.Do(func(inner Enigma) { c, err = NewConfig(inner) })
+		return c, err
+	}
+	return Config{}, fmt.Errorf("can't make a Config of a %T, which this package didn't make", e)
+}
+
+// Config returns the setup of the named model (see Models) according to the
+// key sheet, like NewMachine.
+func (k KeySheet) Config(model string) (Config, error) {
+	e, err := k.NewMachine(model)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{prototype: e.(*enigma)}, nil
+}
+
+// NewMachine returns a new Enigma with the Config's setup, with its rotors
+// at `positions` (see SetRotorPositions). It only copies the rotor positions,
+// so it is cheap, and the Enigma can be changed without affecting the Config
+// or other machines made from it.
+func (c Config) NewMachine(positions []byte) (Enigma, error) {
+	if c.prototype == nil {
+		return nil, errors.New("empty Config; create one with NewConfig")
+	}
+	e := c.prototype.CloneState()
+	if err := e.SetRotorPositions(positions); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithSource returns a copy of the Config that records `source` as where the
+// value of `field` (one of ConfigFields) came from, such as "--rotors" or
+// "default". The Config itself is unchanged.
+func (c Config) WithSource(field, source string) Config {
+	sources := make(map[string]string, len(c.sources)+1)
+	for f, s := range c.sources {
+		sources[f] = s
+	}
+	sources[field] = source
+	c.sources = sources
+	return c
+}
+
+// Source returns where the value of `field` came from, as recorded with
+// WithSource, or "" if that is unknown.
+func (c Config) Source(field string) string {
+	return c.sources[field]
+}
+
+// State returns the Config's setup as a State (see SaveState), with the rotor
+// positions of the machine it was made from.
+func (c Config) State() (State, error) {
+	if c.prototype == nil {
+		return State{}, errors.New("empty Config; create one with NewConfig")
+	}
+	return c.prototype.SaveState()
+}
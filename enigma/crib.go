@@ -0,0 +1,87 @@
+package enigma
+
+import (
+	"fmt"
+	"sort"
+)
+
+// No letter ever encrypts to itself on an Enigma, so a crib (a guess at part
+// of a message's plaintext) can only lie where none of its letters lines up
+// with the same letter in the ciphertext. The codebreakers slid each crib
+// along the ciphertext to find where it could go, and turned each such
+// alignment into a menu for the Bombe.
+
+// CribClashes returns the positions in `crib` (counting from 0) of the
+// letters that would encrypt to themselves if the crib lay at `offset` in
+// `ciphertext`. The crib can only lie there if there are none. Both are
+// written without spaces.
+func CribClashes(ciphertext, crib string, offset int) []int {
+	var clashes []int
+	for i := 0; i < len(crib) && offset+i < len(ciphertext); i++ {
+		if crib[i] == ciphertext[offset+i] {
+			clashes = append(clashes, i)
+		}
+	}
+	return clashes
+}
+
+// CribPositions returns the offsets in `ciphertext` at which `crib` could lie,
+// in order: those where it fits, and none of its letters clashes.
+func CribPositions(ciphertext, crib string) []int {
+	var positions []int
+	for offset := 0; offset+len(crib) <= len(ciphertext); offset++ {
+		if len(CribClashes(ciphertext, crib, offset)) == 0 {
+			positions = append(positions, offset)
+		}
+	}
+	return positions
+}
+
+// A MenuLink is one letter of a crib, lined up with the ciphertext: at
+// Position (counting from 0 in the ciphertext), the machine connected the
+// letters Plain and Cipher. A Bombe menu is the graph of these links.
+type MenuLink struct {
+	Position      int
+	Plain, Cipher byte
+}
+
+// CribMenu returns the menu of `crib` lying at `offset` in `ciphertext`, in
+// order of position, or an error if it doesn't fit there or a letter would
+// encrypt to itself.
+func CribMenu(ciphertext, crib string, offset int) ([]MenuLink, error) {
+	if offset < 0 || offset+len(crib) > len(ciphertext) {
+		return nil, fmt.Errorf("a crib of %v letters doesn't fit at offset %v of %v letters",
+			len(crib), offset, len(ciphertext))
+	}
+	if clashes := CribClashes(ciphertext, crib, offset); len(clashes) > 0 {
+		return nil, fmt.Errorf("%q at position %v of the crib would encrypt to itself",
+			crib[clashes[0]], clashes[0]+1)
+	}
+	menu := make([]MenuLink, len(crib))
+	for i := range crib {
+		menu[i] = MenuLink{Position: offset + i, Plain: crib[i], Cipher: ciphertext[offset+i]}
+	}
+	return menu, nil
+}
+
+// MenuLetters returns the letters in `menu`, the ones linked to the most
+// others first (alphabetically among equals). The best-connected letters
+// were the Bombe's starting points, as their links close loops.
+func MenuLetters(menu []MenuLink) []byte {
+	links := make(map[byte]int)
+	for _, l := range menu {
+		links[l.Plain]++
+		links[l.Cipher]++
+	}
+	var found []byte
+	for letter := range links {
+		found = append(found, letter)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if links[found[i]] != links[found[j]] {
+			return links[found[i]] > links[found[j]]
+		}
+		return found[i] < found[j]
+	})
+	return found
+}
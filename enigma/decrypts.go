@@ -0,0 +1,178 @@
+package enigma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decrypt files hold transcriptions of Bletchley Park's decrypt records, as
+// published from the archives: the naval teleprints were numbered in series
+// such as ZTPG (German naval traffic), and each gives the time of origin
+// (TOO) and of interception (TOI) before the text. Each record starts with
+// its serial, followed by header lines and, after a blank line, the decrypted
+// text. For example:
+//
+//	ZTPG/123456
+//	FROM: BDU
+//	TO: U 505
+//	TOO: 1420/14/5/43
+//	TOI: 1432
+//	KEY: QRS
+//
+//	VON BDU AN U FUENF NULL FUENF ...
+//
+// Times are written as HHMM/day/month/year, or just HHMM for a time on the
+// same day as the time of origin. KEY (the message key), INDICATOR and CIPHER
+// (the ciphertext, where the intercept survives) are optional, and headers
+// other than these are ignored.
+
+// decryptRecord is a record of a decrypt file as it's being read.
+type decryptRecord struct {
+	t        Transmission
+	body     strings.Builder
+	bodyLine int
+}
+
+// isDecryptSerial returns whether `s` is a decrypt serial, such as
+// "ZTPG/123456": a series starting with "ZT", a slash, and a number.
+func isDecryptSerial(s string) bool {
+	parts := strings.Split(s, "/")
+	return len(parts) == 2 && strings.HasPrefix(parts[0], "ZT") &&
+		strings.Trim(parts[0], letters) == "" && isNumber(parts[1])
+}
+
+// parseDecryptTime parses a time in a decrypt header, such as "1420/14/5/43".
+// A time without a date is taken to be on the day of `day`.
+func parseDecryptTime(s string, day time.Time) (time.Time, error) {
+	parts := strings.Split(s, "/")
+	if len(parts[0]) != 4 || !isNumber(parts[0]) || (len(parts) != 1 && len(parts) != 4) {
+		return time.Time{}, fmt.Errorf("times must be like '1420/14/5/43' or '1420', got %q", s)
+	}
+	hour, _ := strconv.Atoi(parts[0][:2])
+	minute, _ := strconv.Atoi(parts[0][2:])
+	if len(parts) == 1 {
+		if day.IsZero() {
+			return time.Time{}, fmt.Errorf("time %q has no date, and there is no TOO to take it from", s)
+		}
+		parts = []string{parts[0], strconv.Itoa(day.Day()), strconv.Itoa(int(day.Month())), strconv.Itoa(day.Year())}
+	}
+	var numbers [3]int
+	for i, part := range parts[1:] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("times must be like '1420/14/5/43', got %q", s)
+		}
+		numbers[i] = n
+	}
+	dayOfMonth, month, year := numbers[0], numbers[1], numbers[2]
+	if year < 100 {
+		year += 1900
+	}
+	t := time.Date(year, time.Month(month), dayOfMonth, hour, minute, 0, 0, time.UTC)
+	if hour > 23 || minute > 59 || t.Day() != dayOfMonth || int(t.Month()) != month {
+		return time.Time{}, fmt.Errorf("%q is not a valid time", s)
+	}
+	return t, nil
+}
+
+// addHeader records the header `name` with the given value.
+func (d *decryptRecord) addHeader(name, value string) error {
+	var err error
+	switch name {
+	case "FROM":
+		d.t.From = value
+	case "TO":
+		d.t.To = value
+	case "TOO":
+		d.t.Sent, err = parseDecryptTime(value, time.Time{})
+	case "TOI":
+		d.t.Received, err = parseDecryptTime(value, d.t.Sent)
+	case "KEY":
+		d.t.MessageKey = value
+	case "INDICATOR":
+		d.t.Indicator = strings.Join(strings.Fields(value), "")
+	case "CIPHER":
+		d.t.Ciphertext = strings.Join(strings.Fields(value), "")
+	}
+	return err
+}
+
+// finish cleans up the record's text and returns it as a Transmission, along
+// with any problems with the text.
+func (d *decryptRecord) finish() (Transmission, []TranscriptionProblem, error) {
+	cleaned, problems := CleanTranscription(d.body.String(), 0)
+	if cleaned == "" {
+		return Transmission{}, nil, fmt.Errorf("%v has no text", d.t.Reference)
+	}
+	d.t.Decrypted = strings.Replace(cleaned, " ", "", -1)
+	for i := range problems {
+		problems[i].Line += d.bodyLine - 1
+		problems[i].Description = fmt.Sprintf("%v: %v", d.t.Reference, problems[i].Description)
+	}
+	return d.t, problems, nil
+}
+
+// ReadDecrypts reads the decrypt records in the file format above into an
+// archive, in the order they appear. Each Transmission has the record's
+// serial as its Reference, and its text, cleaned up like CleanTranscription
+// does, as its Decrypted text; the sender's Plaintext isn't known. Anything
+// suspicious in the texts, such as dropped characters, is reported as a
+// problem, with its line in the file.
+func ReadDecrypts(r io.Reader) ([]Transmission, []TranscriptionProblem, error) {
+	var archive []Transmission
+	var problems []TranscriptionProblem
+	var record *decryptRecord
+	finish := func() error {
+		if record == nil {
+			return nil
+		}
+		t, p, err := record.finish()
+		if err != nil {
+			return err
+		}
+		archive = append(archive, t)
+		problems = append(problems, p...)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case isDecryptSerial(text):
+			if err := finish(); err != nil {
+				return nil, nil, err
+			}
+			record = &decryptRecord{t: Transmission{Reference: text}}
+		case record == nil:
+			if text != "" {
+				return nil, nil, fmt.Errorf("line %v: expected a serial such as 'ZTPG/123456', got %q", line, text)
+			}
+		case record.bodyLine == 0 && text == "":
+			record.bodyLine = line + 1
+		case record.bodyLine == 0:
+			parts := strings.SplitN(text, ":", 2)
+			if len(parts) != 2 {
+				return nil, nil, fmt.Errorf("line %v: expected a header such as 'TOO: 1420/14/5/43', got %q", line, text)
+			}
+			name := strings.ToUpper(strings.TrimSpace(parts[0]))
+			if err := record.addHeader(name, strings.TrimSpace(parts[1])); err != nil {
+				return nil, nil, fmt.Errorf("line %v: %v", line, err)
+			}
+		default:
+			record.body.WriteString(scanner.Text())
+			record.body.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, nil, err
+	}
+	return archive, problems, nil
+}
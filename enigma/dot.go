@@ -0,0 +1,61 @@
+package enigma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// traceComponent names the component a step of a trace passed through, with
+// the position of a rotor or settable reflector, for a DOT graph.
+func traceComponent(t *Trace, step TraceStep) string {
+	if step.Stage == ReflectorStage && t.ReflectorPosition != 0 {
+		return fmt.Sprintf("reflector at %c", t.ReflectorPosition)
+	}
+	if step.Stage != RotorStage {
+		return step.Stage
+	}
+	if step.Slot < len(t.Positions) {
+		return fmt.Sprintf("rotor %v at %c", step.Slot+1, t.Positions[step.Slot])
+	}
+	return fmt.Sprintf("rotor %v", step.Slot+1)
+}
+
+// WriteTraceDOT writes the path of the key press in `trace` as a Graphviz DOT
+// graph, for rendering with standard graph tools (e.g. `dot -Tsvg`). Each
+// component the signal passed through is a node, in order from the key to
+// the lamp, and each wire between them is an edge labeled with the contact
+// the signal was on. Rotors are numbered from 1 on the left.
+func WriteTraceDOT(w io.Writer, trace *Trace) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph trace {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	fmt.Fprintf(b, "\tkey [label=%q, shape=circle];\n", "key "+string(trace.Key))
+	for i, step := range trace.Steps {
+		fmt.Fprintf(b, "\tn%v [label=%q];\n", i, traceComponent(trace, step))
+	}
+	fmt.Fprintf(b, "\tlamp [label=%q, shape=circle];\n", "lamp "+string(trace.Lamp))
+	from, signal := "key", trace.Key
+	for i, step := range trace.Steps {
+		fmt.Fprintf(b, "\t%v -> n%v [label=%q];\n", from, i, string(signal))
+		from, signal = fmt.Sprintf("n%v", i), step.Signal
+	}
+	fmt.Fprintf(b, "\t%v -> lamp [label=%q];\n}\n", from, string(signal))
+	return b.Flush()
+}
+
+// WriteMenuDOT writes `menu` (see CribMenu) as an undirected Graphviz DOT
+// graph: a node for each letter, best connected first, and an edge for each
+// link, labeled with its position. Loops in the graph are what made a menu
+// good for the Bombe.
+func WriteMenuDOT(w io.Writer, menu []MenuLink) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "graph menu {\n\tnode [shape=circle];\n")
+	for _, letter := range MenuLetters(menu) {
+		fmt.Fprintf(b, "\t%c;\n", letter)
+	}
+	for _, link := range menu {
+		fmt.Fprintf(b, "\t%c -- %c [label=\"%v\"];\n", link.Plain, link.Cipher, link.Position)
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Flush()
+}
@@ -1,5 +1,12 @@
 package enigma
 
+import "fmt"
+
+// letters are the keys of most Enigmas, in the order of the contacts they
+// connect to.
+const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// numLetters is the number of contacts on the components of most Enigmas.
 const numLetters uint8 = 26
 
 // Enigma is the code version of the "human" interface of a physical Enigma
@@ -13,11 +20,33 @@ type Enigma interface {
 	// reflector to use was an important secret encoded in the German code books.
 	InstallReflector(reflector Reflector)
 
+	// InstallEntryWheel replaces the Enigma's entry wheel, which connects the
+	// keyboard to the rotors. Each model comes with its own entry wheel (see
+	// EntryWheels), so this is rarely needed. Entry wheels are lettered, so
+	// they only fit machines whose keys are the letters A-Z, not e.g. the
+	// Enigma Z; on others, it returns an error wrapping ErrInvalidEntryWheel.
+	InstallEntryWheel(entryWheel EntryWheel) error
+
 	// InstallRotors places rotors on the Engima's spindle. The rotors are listed
 	// left-to-right. The internal wiring scheme of each rotor, and which set of
 	// rotors would be used, were important secrets encoded in the German code
 	// books.
-	InstallRotors(rotors []Rotor)
+	//
+	// The Enigma I takes 3 rotors. The M4 takes 4, the leftmost of which is a
+	// thin "Greek" rotor (Beta or Gamma) that never turns, used together with a
+	// thin reflector.
+	//
+	// It returns an error, and installs nothing, if a rotor is labeled
+	// differently from the Enigma's keys (e.g. a lettered rotor on an Enigma
+	// Z). Installing rotors resets the ring settings and rotor positions.
+	InstallRotors(rotors []Rotor) error
+
+	// ReplaceRotor swaps the rotor in one slot, counting from 0 on the left,
+	// for `rotor`, as operators did when the wheel order changed during the
+	// day. The new rotor takes over the ring setting and position of the old
+	// one, and the other rotors keep theirs. It returns an error, and changes
+	// nothing, if there is no such slot or the rotor doesn't fit.
+	ReplaceRotor(slot int, rotor Rotor) error
 
 	// SetRingSettings determines the offset to which the rotor rings are set.
 	//
@@ -29,8 +58,12 @@ type Enigma interface {
 	//
 	// The settings are (as in real Enigma operation) expressed as a list of
 	// letters (with 'A' representing a logical offset of 0), with the first
-	// setting representing the offset of the leftmost ring.
-	SetRingSettings(settings []byte)
+	// setting representing the offset of the leftmost ring. On the Enigma Z,
+	// they are digits, with '1' representing an offset of 0.
+	//
+	// It returns an error, and changes nothing, unless there is exactly one
+	// setting per installed rotor, each one of the Enigma's keys.
+	SetRingSettings(settings []byte) error
 
 	// SetRotorPositions will rotate a rotor to a given starting position. The
 	// starting position of each rotor was another important secret encoded in
@@ -38,20 +71,116 @@ type Enigma interface {
 	//
 	// The settings are (as in real Enigma operation) expressed as a list of
 	// letters (with 'A' representing a logical rotation  of 0), with the first
-	// position representing the rotation of the leftmost ring.
-	SetRotorPositions(positions []byte)
+	// position representing the rotation of the leftmost ring. On the Enigma
+	// Z, they are digits, like the ring settings. Like SetRingSettings, it
+	// returns an error unless there is exactly one valid position per rotor.
+	SetRotorPositions(positions []byte) error
+
+	// SaveState returns the Enigma's reflector, rotors, settings and rotor
+	// positions, which LoadState can restore. Components that aren't one of
+	// Rotors or Reflectors are saved by their wiring. It returns an error for
+	// rotors that aren't WiredRotors, which can't be written down.
+	SaveState() (State, error)
+
+	// LoadState sets the Enigma up as in `state`, as returned by SaveState
+	// on an Enigma of the same model. It returns an error, and changes
+	// nothing, if the state doesn't fit the Enigma. The rotor positions become
+	// the ones Reset returns to.
+	LoadState(state State) error
+
+	// MarshalJSON encodes the Enigma's state (see SaveState) as JSON, so that
+	// json.Marshal can store an Enigma. UnmarshalJSON loads such a state, like
+	// LoadState, onto an Enigma of the same model.
+	MarshalJSON() ([]byte, error)
+	UnmarshalJSON(data []byte) error
+
+	// RotorPositions returns the positions the rotors are at, as shown in the
+	// windows above them, left to right and written like SetRotorPositions
+	// takes them. Typing turns the rotors, so these change with every key.
+	RotorPositions() []byte
+
+	// ReflectorPosition returns the position of a reflector that can be set
+	// (see SetReflectorPosition), or 0 for models whose reflector is fixed.
+	// On the Enigma G the reflector turns too, so together with the rotor
+	// positions this is the machine's full mechanical state.
+	ReflectorPosition() byte
+
+	// Reflector returns the installed reflector, or the zero Reflector if
+	// there is none.
+	Reflector() Reflector
+
+	// Rotors returns the installed rotors, left to right.
+	Rotors() []Rotor
+
+	// RingSettings returns the ring settings of the rotors, left to right,
+	// written like SetRingSettings takes them.
+	RingSettings() []byte
+
+	// PlugPairs returns the plugboard's pairs, written like "AB", in
+	// alphabetical order.
+	PlugPairs() []string
+
+	// Reset turns the rotors (and a settable reflector) back to the positions
+	// they were last set to, e.g. to decrypt a message right after encrypting
+	// it. If they were never set since the rotors were installed, that is the
+	// first position.
+	Reset()
 
 	// SetPlugboard configures the Enigma to use the given plugboard
 	// configuration. The plugboard configuration was another important secret
 	// encoded in the German code books.
 	SetPlugboard(plugboard Plugboard)
 
+	// SetReflectorPosition rotates the reflector to a given starting position,
+	// expressed as a letter like the rotor positions. Only some models, such as
+	// the Enigma G, have a reflector that can be set; others ignore this. It
+	// returns an error if the position isn't one of the Enigma's keys.
+	SetReflectorPosition(position byte) error
+
 	// KeyPress takes the value of the key pressed on the keyboard, and returns
-	// the value of the light that would light up in response.
+	// the value of the light that would light up in response. It doesn't
+	// check its input, or that the Enigma is fully set up; for that, use
+	// TryKeyPress.
 	KeyPress(k byte) byte
+
+	// TryKeyPress does the same as KeyPress, but first checks that a fitting
+	// reflector is installed and that `k` is one of the Enigma's keys. If
+	// not, it returns an error, and the rotors don't move. Lowercase letters
+	// are pressed as the uppercase keys.
+	TryKeyPress(k byte) (byte, error)
+
+	// TraceKeyPress does the same as KeyPress, and records the signal's path
+	// through the machine in `trace`, overwriting what it held. Reuse the
+	// trace across key presses to avoid allocating (see NewTrace).
+	TraceKeyPress(k byte, trace *Trace) byte
+
+	// OnStep sets a function to call every time the rotors turn, on every
+	// key press, with the new rotor positions (see RotorPositions), e.g. to
+	// show them live. It replaces any previous one; nil removes it. Copies
+	// of the Enigma made with Clone or CloneState don't call it.
+	OnStep(observer func(positions []byte))
+
+	// Clone returns a copy of the Enigma, with the same components, settings
+	// and rotor positions, that works independently of it: typing on one
+	// doesn't turn the other's rotors, so each can be used in its own
+	// goroutine. The plugboard is copied too, so that changing the Plugboard
+	// passed to SetPlugboard affects neither. A custom SteppingMechanism is
+	// shared, so it must not keep state between key presses.
+	Clone() Enigma
+
+	// CloneState is a cheaper Clone, for fanning out many copies of a machine
+	// that are only set and typed on, e.g. to try every rotor position. Only
+	// the rotor positions and other state that typing changes are copied; the
+	// plugboard is shared with the original, and must not be changed
+	// except through SetPlugboard.
+	CloneState() Enigma
 }
 
 type enigma struct {
+	// The keys of this Enigma, in the order of the contacts they connect to.
+	// All of its components must have this many contacts.
+	alphabet Alphabet
+
 	// The Enigma's plugboard, if any. If no plugboard is present this is nil.
 	plugboard *Plugboard
 
@@ -63,159 +192,427 @@ type enigma struct {
 	// The reflector is the leftmost component in the Engima's physical spindle.
 	reflector Reflector
 
+	// The rotation of the reflector, for models where it can be set (and, on
+	// the Enigma G, turns during operation). Otherwise this is always 0.
+	reflectorRotation uint8
+	settableReflector bool
+
+	// The rotor positions and reflector rotation as last set, for Reset.
+	startRotations         []uint8
+	startReflectorRotation uint8
+
+	// The mechanism that turns the rotors on every key press. If nil, this is
+	// LeverStepping.
+	stepping SteppingMechanism
+
+	// Buffers for the stepping mechanism, with an entry per rotor.
+	wheels []WheelState
+	turns  []bool
+
+	// The entry wheel (stator), if it isn't straight. See EntryWheel.
+	entry *EntryWheel
+
 	// The rotors in this machine, left-to-right.
 	rotor []rotorState
+
+	// Called with the rotor positions after each step, if set. See OnStep.
+	onStep func(positions []byte)
+
+	// The components and plug pairs that a machine of some period may use
+	// (see NewForDate), or nil if it may use any.
+	inventory *Inventory
 }
 
 type rotorState struct {
 	Rotor
 
-	// A rotor needs to map its contacts both ways, since contacts get
-	// activated both left-to-right and right-to-left. The left-to-right
-	// mapping is provided by the user; we compute its inverse here.
-	lrMapping [numLetters]byte
-
 	// A rotor can rotate its internal wiring relative to its outside
 	// contacts, thereby changing the position of the wiring relative
 	// to the turnover points and starting position. This is the offset
 	// to which the ring is set.
 	ringsetting uint8
 
-	// A rotor can be in 'numLetters' different positions. We number
-	// these 0..('numLetters'-1).
+	// A rotor can be in as many different positions as it has contacts. We
+	// number these 0..(contacts-1).
 	rotation uint8
 }
 
-func setUpRotor(base Rotor, r *rotorState) {
-	r.turnoverPoints = base.turnoverPoints
-	r.rlMapping = base.rlMapping
+func (e *enigma) InstallRotors(rotors []Rotor) error {
+	for i, rotor := range rotors {
+		if rotor.Alphabet() != e.alphabet {
+			return fmt.Errorf("%w: rotor %v is labeled %v, but this Enigma's keys are %v",
+				ErrInvalidRotor, i+1, rotor.Alphabet(), e.alphabet)
+		}
+		if !e.inventory.hasRotor(rotor) {
+			return fmt.Errorf("%w: rotor %v was not in use at the time", ErrInvalidRotor, i+1)
+		}
+	}
+	e.rotor = make([]rotorState, len(rotors))
+	e.wheels = make([]WheelState, len(rotors))
+	e.turns = make([]bool, len(rotors))
+	e.startRotations = make([]uint8, len(rotors))
+	for i, rotor := range rotors {
+		e.rotor[i].Rotor = rotor
+	}
+	return nil
+}
 
-	// From the rlMapping we can compute the lrMapping. The other configuration
-	// values will be provided by the user later.
-	for i := uint8(0); i < numLetters; i++ {
-		r.lrMapping[r.rlMapping[i]] = byte(i)
+func (e *enigma) ReplaceRotor(slot int, rotor Rotor) error {
+	if slot < 0 || slot >= len(e.rotor) {
+		return fmt.Errorf("%w: there is no slot %v among %v rotors", ErrWrongRotorCount, slot, len(e.rotor))
+	}
+	if rotor.Alphabet() != e.alphabet {
+		return fmt.Errorf("%w: the rotor is labeled %v, but this Enigma's keys are %v",
+			ErrInvalidRotor, rotor.Alphabet(), e.alphabet)
 	}
+	if !e.inventory.hasRotor(rotor) {
+		return fmt.Errorf("%w: the rotor was not in use at the time", ErrInvalidRotor)
+	}
+	e.rotor[slot].Rotor = rotor
+	return nil
 }
 
-func (e *enigma) InstallRotors(rotors []Rotor) {
-	e.rotor = make([]rotorState, len(rotors))
-	for i, rotor := range rotors {
-		setUpRotor(rotor, &e.rotor[i])
+// contacts returns the number of contacts on this Enigma's components.
+func (e *enigma) contacts() uint8 {
+	return uint8(len(e.alphabet))
+}
+
+// index returns the number of `key` in this Enigma's alphabet, which is the
+// contact that it connects to on a straight entry wheel.
+func (e *enigma) index(key byte) uint8 {
+	return uint8(e.alphabet.Index(key))
+}
+
+// checkSettings returns an error unless `settings` holds one of this
+// Enigma's keys for every installed rotor. `what` names the settings.
+func (e *enigma) checkSettings(what string, settings []byte) error {
+	if len(settings) != len(e.rotor) {
+		return fmt.Errorf("%w: got %v %v for %v rotors", ErrWrongRotorCount, len(settings), what, len(e.rotor))
 	}
+	for _, s := range settings {
+		if !e.alphabet.Contains(s) {
+			return fmt.Errorf("%w: %q in the %v is not one of %v", ErrBadLetter, s, what, e.alphabet)
+		}
+	}
+	return nil
 }
 
-func (e *enigma) SetRingSettings(settings []byte) {
+func (e *enigma) SetRingSettings(settings []byte) error {
+	if err := e.checkSettings("ring settings", settings); err != nil {
+		return err
+	}
 	for i, pos := range settings {
-		e.rotor[i].ringsetting = pos - 'A'
+		e.rotor[i].ringsetting = e.index(pos)
 	}
+	return nil
 }
 
-func (e *enigma) SetRotorPositions(positions []byte) {
+func (e *enigma) SetRotorPositions(positions []byte) error {
+	if err := e.checkSettings("rotor positions", positions); err != nil {
+		return err
+	}
 	for i, pos := range positions {
-		e.rotor[i].rotation = pos - 'A'
+		e.rotor[i].rotation = e.index(pos)
+		e.startRotations[i] = e.rotor[i].rotation
 	}
+	return nil
 }
 
-func (e *enigma) getRotorPositions() []byte {
+func (e *enigma) Reset() {
+	for i, rotation := range e.startRotations {
+		e.rotor[i].rotation = rotation
+	}
+	e.reflectorRotation = e.startReflectorRotation
+}
+
+func (e *enigma) RotorPositions() []byte {
 	positions := make([]byte, len(e.rotor))
 	for i, rotor := range e.rotor {
-		positions[i] = rotor.rotation + 'A'
+		positions[i] = e.alphabet[rotor.rotation]
 	}
 	return positions
 }
 
+func (e *enigma) ReflectorPosition() byte {
+	if !e.settableReflector {
+		return 0
+	}
+	return e.alphabet[e.reflectorRotation]
+}
+
+func (e *enigma) Reflector() Reflector {
+	return e.reflector
+}
+
+func (e *enigma) Rotors() []Rotor {
+	rotors := make([]Rotor, len(e.rotor))
+	for i, r := range e.rotor {
+		rotors[i] = r.Rotor
+	}
+	return rotors
+}
+
+func (e *enigma) RingSettings() []byte {
+	settings := make([]byte, len(e.rotor))
+	for i, r := range e.rotor {
+		settings[i] = e.alphabet[r.ringsetting]
+	}
+	return settings
+}
+
+func (e *enigma) PlugPairs() []string {
+	return e.plugboard.pairs()
+}
+
 func (e *enigma) InstallReflector(reflector Reflector) {
 	e.reflector = reflector
 }
 
+func (e *enigma) InstallEntryWheel(entryWheel EntryWheel) error {
+	if e.alphabet != letters {
+		return fmt.Errorf("%w: entry wheels are labeled %v, but this Enigma's keys are %v",
+			ErrInvalidEntryWheel, letters, e.alphabet)
+	}
+	e.entry = &entryWheel
+	return nil
+}
+
 func (e *enigma) SetPlugboard(plugboard Plugboard) {
 	e.plugboard = &plugboard
 }
 
+func (e *enigma) SetReflectorPosition(position byte) error {
+	if !e.alphabet.Contains(position) {
+		return fmt.Errorf("%w: reflector position %q is not one of %v", ErrBadLetter, position, e.alphabet)
+	}
+	if e.settableReflector {
+		e.reflectorRotation = e.index(position)
+		e.startReflectorRotation = e.reflectorRotation
+	}
+	return nil
+}
+
 func (e *enigma) rotate() {
-	for i := 0; i < len(e.rotor); i++ {
-		// A rotor turns when any one of the following is true:
-		// - It is the rightmost rotor (which always turns).
-		turn := i == len(e.rotor)-1
-		// - It is in a notched position itself, and there's a rotor to its left for
-		//   it to push. This condition causes the "double step" effect for (only)
-		//   the middle rotor in a 3-rotor machine.
-		turn = turn || (i > 0 && i < len(e.rotor)-1 && e.rotor[i].turnoverPoints[e.rotor[i].rotation])
-		// - Its right neighbour is in a notched position and will push it.
-		turn = turn || e.rotor[i+1].turnoverPoints[e.rotor[i+1].rotation]
+	for i, r := range e.rotor {
+		e.wheels[i] = WheelState{
+			Position: r.rotation,
+			Notched:  r.Notched(r.rotation),
+			Thin:     r.Thin(),
+		}
+		e.turns[i] = false
+	}
+	var stepping SteppingMechanism = LeverStepping{}
+	if e.stepping != nil {
+		stepping = e.stepping
+	}
+	if stepping.Step(e.wheels, e.turns) {
+		e.reflectorRotation = (e.reflectorRotation + 1) % e.contacts()
+	}
+	for i, turn := range e.turns {
 		if turn {
-			e.rotor[i].rotation = (e.rotor[i].rotation + 1) % numLetters
+			e.rotor[i].rotation = (e.rotor[i].rotation + 1) % e.contacts()
 		}
 	}
 }
 
-func addRotation(rot uint8, ringsetting uint8, contact uint8) uint8 {
-	// Adds 'numLetters' to ensure we're always mod-ing a positive number.
-	return (contact + rot - ringsetting + numLetters) % numLetters
+func addRotation(rot uint8, ringsetting uint8, contact uint8, contacts uint8) uint8 {
+	// Adds 'contacts' to ensure we're always mod-ing a positive number.
+	return (contact + rot - ringsetting + contacts) % contacts
 }
 
-func removeRotation(rot uint8, ringsetting uint8, contact uint8) uint8 {
-	// Adds '2*numLetters' to ensure we're always mod-ing a
+func removeRotation(rot uint8, ringsetting uint8, contact uint8, contacts uint8) uint8 {
+	// Adds '2*contacts' to ensure we're always mod-ing a
 	// positive number.
-	return (contact - rot + ringsetting + 2*numLetters) % numLetters
+	return (contact - rot + ringsetting + 2*contacts) % contacts
 }
 
 func (e *enigma) KeyPress(letter byte) byte {
+	return e.press(letter, nil)
+}
+
+func (e *enigma) TraceKeyPress(k byte, trace *Trace) byte {
+	trace.Key = k
+	trace.Steps = trace.Steps[:0]
+	lamp := e.press(k, trace)
+	trace.Lamp = lamp
+	return lamp
+}
+
+// press presses the key `letter`, recording its path in `trace` unless it
+// is nil, and returns the lamp that lights up.
+func (e *enigma) press(letter byte, trace *Trace) byte {
 	// Rotate the rotors for the next key press.
 	e.rotate()
+	if e.onStep != nil {
+		e.onStep(e.RotorPositions())
+	}
+	n := e.contacts()
+	if trace != nil {
+		trace.Positions = trace.Positions[:0]
+		for _, r := range e.rotor {
+			trace.Positions = append(trace.Positions, e.alphabet[r.rotation])
+		}
+		trace.ReflectorPosition = e.ReflectorPosition()
+	}
 
 	// Run the key press through the plugboard.
 	letter = e.plugboard.mapLetter(letter)
+	trace.record(PlugboardStage, 0, letter)
 
 	// Determine the input on the stator. Before the stator, while in the keyboard/plugboard/chassis
 	// it's easy to talk about each contact/wire as representing a single letter. In the rotors and
 	// reflector this is harder, because the ring setting can rotate the letter-markings on the rotor
 	// relative to the internal wiring. It's easier to talk about "contacts" 0-25 while we're in the
 	// rotors and reflector. The stator is the conversion-point.
-	contact := letter - 'A'
+	contact := e.entry.toContact(e.index(letter))
+	trace.record(EntryWheelStage, 0, e.alphabet[contact])
 
 	// Pass through rotors, right to left.
 	for i := len(e.rotor) - 1; i >= 0; i-- {
 		// Connect from the chassis to the next rotor.
 		r := &e.rotor[i]
-		contact = addRotation(r.rotation, r.ringsetting, contact)
+		contact = addRotation(r.rotation, r.ringsetting, contact, n)
 
 		// Perform the mapping.
-		contact = r.rlMapping[contact]
+		contact = r.Map(true, contact)
 
 		// Connect back to the chassis. Note that in the real Enigma there was no
 		// chassis in between rotors, but doing all operations relative to the
 		// 0-rotation chassis helps us keep our code sane.
-		contact = removeRotation(r.rotation, r.ringsetting, contact)
+		contact = removeRotation(r.rotation, r.ringsetting, contact, n)
+		trace.record(RotorStage, i, e.alphabet[contact])
 	}
 
 	// Pass through reflector.
+	contact = addRotation(e.reflectorRotation, 0, contact, n)
 	contact = e.reflector.mapping[contact]
+	contact = removeRotation(e.reflectorRotation, 0, contact, n)
+	trace.record(ReflectorStage, 0, e.alphabet[contact])
 
 	// Pass through rotors, left to right.
 	for i := 0; i < len(e.rotor); i++ {
 		// Connect from the chassis to the next rotor.
 		r := &e.rotor[i]
-		contact = addRotation(r.rotation, r.ringsetting, contact)
+		contact = addRotation(r.rotation, r.ringsetting, contact, n)
 
 		// Perform the mapping.
-		contact = r.lrMapping[contact]
+		contact = r.Map(false, contact)
 
 		// Connect back to the chassis.
-		contact = removeRotation(r.rotation, r.ringsetting, contact)
+		contact = removeRotation(r.rotation, r.ringsetting, contact, n)
+		trace.record(RotorStage, i, e.alphabet[contact])
 	}
 
 	// Pass back through the stator.
-	letter = contact + 'A'
+	letter = e.alphabet[e.entry.toKey(contact)]
+	trace.record(EntryWheelStage, 0, letter)
 
 	// Second pass through the plugboard.
 	letter = e.plugboard.mapLetter(letter)
+	trace.record(PlugboardStage, 0, letter)
 
 	return letter
 }
 
+func (e *enigma) TryKeyPress(k byte) (byte, error) {
+	key, err := e.checkKey(k)
+	if err != nil {
+		return 0, err
+	}
+	return e.KeyPress(key), nil
+}
+
+// checkKey returns the key that `k` stands for (see key), or the error that
+// TryKeyPress would return for it, without pressing it.
+func (e *enigma) checkKey(k byte) (byte, error) {
+	key, ok, err := e.lookUpKey(k)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is not one of %v", ErrBadLetter, k, e.alphabet)
+	}
+	return key, nil
+}
+
+// lookUpKey returns the key that `k` stands for and whether there is one,
+// like key, or an error if the machine can't type at all. Unlike checkKey,
+// it never allocates.
+func (e *enigma) lookUpKey(k byte) (byte, bool, error) {
+	if e.reflector.alphabet == "" {
+		return 0, false, ErrMissingReflector
+	}
+	if e.reflector.alphabet != e.alphabet {
+		return 0, false, fmt.Errorf("%w: the reflector is labeled %v, but this Enigma's keys are %v",
+			ErrInvalidReflector, e.reflector.alphabet, e.alphabet)
+	}
+	if !e.inventory.hasReflector(e.reflector) {
+		return 0, false, fmt.Errorf("%w: the reflector was not in use at the time", ErrInvalidReflector)
+	}
+	if n := e.plugboard.count(); !e.inventory.hasPlugPairs(n) {
+		return 0, false, fmt.Errorf("%w: the code books called for %v to %v plug pairs, not %v",
+			ErrWrongPlugCount, e.inventory.MinPlugPairs, e.inventory.MaxPlugPairs, n)
+	}
+	key, ok := e.key(k)
+	return key, ok, nil
+}
+
+// key returns the key that `k` stands for on this Enigma, and whether there
+// is one: `k` itself, or for a lowercase letter, the uppercase key.
+func (e *enigma) key(k byte) (byte, bool) {
+	if e.alphabet.Contains(k) {
+		return k, true
+	}
+	if k >= 'a' && k <= 'z' && e.alphabet.Contains(k-'a'+'A') {
+		return k - 'a' + 'A', true
+	}
+	return 0, false
+}
+
+func (e *enigma) OnStep(observer func(positions []byte)) {
+	e.onStep = observer
+}
+
+func (e *enigma) Clone() Enigma {
+	c := e.CloneState().(*enigma)
+	if e.plugboard != nil {
+		plugboard := Plugboard{mapping: make(map[byte]byte, len(e.plugboard.mapping))}
+		for k, v := range e.plugboard.mapping {
+			plugboard.mapping[k] = v
+		}
+		c.plugboard = &plugboard
+	}
+	return c
+}
+
+func (e *enigma) CloneState() Enigma {
+	c := *e
+	// The rotors' wiring never changes, but their positions do, and the
+	// stepping buffers are written on every key press.
+	c.rotor = append([]rotorState(nil), e.rotor...)
+	c.startRotations = append([]uint8(nil), e.startRotations...)
+	c.wheels = make([]WheelState, len(e.wheels))
+	c.turns = make([]bool, len(e.turns))
+	c.onStep = nil
+	return &c
+}
+
 // New creates a new Enigma machine.
 func New() Enigma {
-	enigma := &enigma{}
+	enigma := &enigma{alphabet: letters}
 	return enigma
 }
+
+// NewWithAlphabet creates a new Enigma machine like New, whose keys are the
+// symbols of `alphabet` instead of the letters A-Z. Its ring settings and rotor
+// positions are written in the same symbols, and its components must be made
+// for it, with MakeAlphabetRotor and MakeAlphabetReflector.
+func NewWithAlphabet(alphabet Alphabet) Enigma {
+	return &enigma{alphabet: alphabet}
+}
+
+// NewWithStepping creates a new Enigma machine like New, but with the given
+// mechanism for turning its rotors.
+func NewWithStepping(stepping SteppingMechanism) Enigma {
+	return &enigma{alphabet: letters, stepping: stepping}
+}
@@ -1,8 +1,14 @@
 package enigma
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,10 +23,6 @@ func MakeExampleEnigma(t *testing.T) Enigma {
 	return enigma
 }
 
-func ResetExampleEnigma(e Enigma) {
-	e.SetRotorPositions([]byte{'A', 'A', 'A'})
-}
-
 func TestBasic(t *testing.T) {
 	assert := assert.New(t)
 	enigma := MakeExampleEnigma(t)
@@ -33,7 +35,7 @@ func TestBasic(t *testing.T) {
 	assert.Equal("BDZGO", encrypted, "Wikipedia disagrees with this encryption")
 
 	// Reset the rotor positions for decryption.
-	ResetExampleEnigma(enigma)
+	enigma.Reset()
 	decrypted := Type(enigma, encrypted)
 	assert.Equal(input, decrypted, "Failed to reverse encryption.")
 }
@@ -51,7 +53,7 @@ func TestRingSetting(t *testing.T) {
 	assert.Equal("EWTYX", encrypted, "Wikipedia disagrees with this encryption")
 
 	// Reset the rotor positions for decryption.
-	ResetExampleEnigma(enigma)
+	enigma.Reset()
 	decrypted := Type(enigma, encrypted)
 	assert.Equal(input, decrypted, "Failed to reverse encryption.")
 }
@@ -63,12 +65,12 @@ func TestRotation(t *testing.T) {
 	encrypted2 := Type(enigma, "A")
 	assert.NotEqual(encrypted1, encrypted2, "The first rotor isn't rotating")
 
-	ResetExampleEnigma(enigma)
+	enigma.Reset()
 	encrypted1 = Type(enigma, strings.Repeat("A", 26))
 	encrypted2 = Type(enigma, strings.Repeat("A", 26))
 	assert.NotEqual(encrypted1, encrypted2, "The second rotor isn't rotating")
 
-	ResetExampleEnigma(enigma)
+	enigma.Reset()
 	encrypted1 = Type(enigma, strings.Repeat("A", 26*26))
 	encrypted2 = Type(enigma, strings.Repeat("A", 26*26))
 	assert.NotEqual(encrypted1, encrypted2, "The third rotor isn't rotating")
@@ -84,28 +86,530 @@ func TestSingleDoubleStep(t *testing.T) {
 	// Normal sequence.
 	e.SetRotorPositions([]byte{'A', 'A', 'U'})
 	e.KeyPress('A') // Could be any key press.
-	assert.Equal([]byte{'A', 'A', 'V'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'A', 'A', 'V'}, e.RotorPositions(), "The rotor positions are wrong")
 	e.KeyPress('A')
-	assert.Equal([]byte{'A', 'B', 'W'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'A', 'B', 'W'}, e.RotorPositions(), "The rotor positions are wrong")
 	e.KeyPress('A')
-	assert.Equal([]byte{'A', 'B', 'X'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'A', 'B', 'X'}, e.RotorPositions(), "The rotor positions are wrong")
 
 	// Double step sequence.
 	e.SetRotorPositions([]byte{'A', 'D', 'U'}) // Normal step of right rotor.
 	e.KeyPress('A')                            // Right rotor (III) goes in V - notch position.
-	assert.Equal([]byte{'A', 'D', 'V'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'A', 'D', 'V'}, e.RotorPositions(), "The rotor positions are wrong")
 	e.KeyPress('A') // Right rotor steps, takes middle rotor (II) one further to E - notch position.
-	assert.Equal([]byte{'A', 'E', 'W'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'A', 'E', 'W'}, e.RotorPositions(), "The rotor positions are wrong")
 	e.KeyPress('A') // Normal step of right, double step of middle, normal step of left.
-	assert.Equal([]byte{'B', 'F', 'X'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'B', 'F', 'X'}, e.RotorPositions(), "The rotor positions are wrong")
 	e.KeyPress('A') // Normal step of right rotor.
-	assert.Equal([]byte{'B', 'F', 'Y'}, e.getRotorPositions(), "The rotor positions are wrong")
+	assert.Equal([]byte{'B', 'F', 'Y'}, e.RotorPositions(), "The rotor positions are wrong")
+}
+
+func TestDoubleNotch(t *testing.T) {
+	assert := assert.New(t)
+	enig := MakeExampleEnigma(t)
+	enig.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["VI"]})
+	enig.SetRingSettings([]byte{'A', 'A', 'A'})
+	e := enig.(*enigma)
+
+	// Rotor VI turns over its neighbour at both its M and Z notches.
+	e.SetRotorPositions([]byte{'A', 'A', 'L'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'A', 'M'}, e.RotorPositions(), "The rotor positions are wrong")
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'B', 'N'}, e.RotorPositions(), "The rotor positions are wrong")
+	e.SetRotorPositions([]byte{'A', 'B', 'Z'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'C', 'A'}, e.RotorPositions(), "The rotor positions are wrong")
+}
+
+func TestMakeRotor(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := MakeRotor("JPGVOUMFYQBENHZRDKASXLICTW", 'Z', 'M')
+	assert.NoError(err)
+	assert.Equal(Rotors["VI"], *r, "Rotor VI was not recreated")
+	r, err = MakeRotor("EKMFLGDQVZNTOWYHXUSPAIBRCJ", 'Q')
+	assert.NoError(err)
+	assert.Equal(Rotors["I"], *r, "Rotor I was not recreated")
+
+	_, err = MakeRotor("JPGVOUMFYQBENHZRDKASXLICTW", 'Z', '1')
+	assert.Error(err, "Invalid turnover points should be rejected")
+	_, err = MakeRotor("JPGVOUMFYQBENHZRDKASXLICTW", 'Z', 'M', 'Z')
+	assert.Error(err, "Duplicate turnover points should be rejected")
+	_, err = MakeRotor("JPGVOUMFYQBENHZRDKASXLICTT", 'Z')
+	assert.Error(err, "Invalid wirings should be rejected")
+}
+
+// countingRotor is a Rotor that counts how often its wrapped rotor is used.
+type countingRotor struct {
+	Rotor
+	maps *int
+}
+
+func (r countingRotor) Map(forward bool, contact byte) byte {
+	*r.maps++
+	return r.Rotor.Map(forward, contact)
+}
+
+// brokenRotor is a Rotor whose wiring doesn't lead back the way it came.
+type brokenRotor struct {
+	WiredRotor
+}
+
+func (r brokenRotor) Map(forward bool, contact byte) byte {
+	if forward {
+		return r.WiredRotor.Map(forward, contact)
+	}
+	return 0
+}
+
+func TestRotorInterface(t *testing.T) {
+	assert := assert.New(t)
+
+	maps := 0
+	enigma := MakeExampleEnigma(t)
+	enigma.InstallRotors([]Rotor{
+		countingRotor{Rotors["I"], &maps},
+		countingRotor{Rotors["II"], &maps},
+		countingRotor{Rotors["III"], &maps},
+	})
+	enigma.SetRingSettings([]byte{'A', 'A', 'A'})
+	enigma.Reset()
+	assert.Equal("BDZGO", Type(enigma, "AAAAA"), "A wrapped rotor should behave the same")
+	assert.Equal(5*6, maps, "Every key press passes through 3 rotors twice")
+
+	assert.NoError(ValidateRotor(Rotors["I"]))
+	assert.Error(ValidateRotor(brokenRotor{Rotors["I"].(WiredRotor)}), "The broken rotor should be rejected")
+}
+
+func TestEntryWheel(t *testing.T) {
+	assert := assert.New(t)
+
+	// A straight entry wheel changes nothing.
+	enigma := MakeExampleEnigma(t)
+	assert.NoError(enigma.InstallEntryWheel(EntryWheels["ABC"]))
+	assert.Equal("BDZGO", Type(enigma, "AAAAA"), "A straight entry wheel should have no effect")
+
+	w, err := MakeEntryWheel("QWERTZUIOASDFGHJKPYXCVBNML")
+	assert.NoError(err)
+	assert.Equal(EntryWheels["QWERTZU"], *w, "The QWERTZU entry wheel was not recreated")
+	_, err = MakeEntryWheel("QWERTZUIOASDFGHJKPYXCVBNMQ")
+	assert.Error(err, "Invalid wirings should be rejected")
+
+	// Entry wheels are lettered, so they don't fit machines with other keys.
+	for _, alphabet := range []Alphabet{Alphanumeric, Digits} {
+		e := NewWithAlphabet(alphabet)
+		assert.ErrorIs(e.InstallEntryWheel(EntryWheels["QWERTZU"]), ErrInvalidEntryWheel)
+	}
+	e := NewWithAlphabet(Alphanumeric)
+	rotor, err := MakeAlphabetRotor(Alphanumeric, "BCDEFGHIJKLMNOPQRSTUVWXYZ0123456789A")
+	assert.NoError(err)
+	reflector, err := MakeAlphabetReflector(Alphanumeric, "BADCFEHGJILKNMPORQTSVUXWZY1032547698")
+	assert.NoError(err)
+	assert.NoError(e.InstallRotors([]Rotor{*rotor}))
+	e.InstallReflector(*reflector)
+	e.InstallEntryWheel(EntryWheels["QWERTZU"])
+	_, err = e.TryKeyPress('9')
+	assert.NoError(err, "A rejected entry wheel should not be installed")
+}
+
+func TestAlphabet(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, Letters.Index('A'))
+	assert.Equal(-1, Letters.Index('a'))
+	assert.Equal(26, Alphanumeric.Index('0'))
+	_, err := NewAlphabet("ABCA")
+	assert.Error(err, "Repeated symbols should be rejected")
+	_, err = NewAlphabet("ABC")
+	assert.Error(err, "A reflector can't pair up an odd number of symbols")
+	_, err = NewAlphabet("AB D")
+	assert.Error(err, "Spaces should be rejected")
+
+	// A machine with the classic alphabet is just like the original.
+	enigma := NewWithAlphabet(Letters)
+	enigma.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]})
+	enigma.InstallReflector(Reflectors["B"])
+	enigma.SetRingSettings([]byte{'A', 'A', 'A'})
+	enigma.Reset()
+	assert.Equal("BDZGO", Type(enigma, "AAAAA"))
+
+	var rotors []Rotor
+	for _, wiring := range []string{
+		"5YKF31IXOM2W8PAT4HV9NC7GQBSL6R0EDZJU",
+		"1Z6MIXAQVYEFO97KNP0B2CWR45GU3SDLTH8J",
+		"IJGWDU4XAM71QZ3O5LT29RCVBNP8KYF06HES",
+	} {
+		r, err := MakeAlphabetRotor(Alphanumeric, wiring, '9')
+		assert.NoError(err)
+		rotors = append(rotors, r)
+	}
+	reflector, err := MakeAlphabetReflector(Alphanumeric, "ZW2PHO8EUYTNVLFD06XKIMBSJAQ7C954R1G3")
+	assert.NoError(err)
+	_, err = MakeAlphabetRotor(Alphanumeric, letters)
+	assert.Error(err, "A rotor needs a contact for every symbol")
+
+	enigma = NewWithAlphabet(Alphanumeric)
+	enigma.InstallRotors(rotors)
+	enigma.InstallReflector(*reflector)
+	enigma.SetRingSettings([]byte("A0Z"))
+	enigma.SetRotorPositions([]byte("9X3"))
+	plaintext := "ANKUNFT 1800 PLANQUADRAT AJ9863"
+	ciphertext := Type(enigma, plaintext)
+	for i := range plaintext {
+		if plaintext[i] != ' ' {
+			assert.NotEqual(plaintext[i], ciphertext[i], "No symbol encrypts to itself")
+		}
+	}
+	enigma.Reset()
+	assert.Equal(plaintext, Type(enigma, ciphertext), "Decryption should reverse encryption")
+}
+
+func TestReset(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	e.SetRotorPositions([]byte("QEV"))
+	encrypted := Type(e, "HELLOWORLD")
+	e.Reset()
+	assert.Equal("HELLOWORLD", Type(e, encrypted), "Reset should return to the positions last set")
+
+	// Installing rotors resets them to the first position.
+	e.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]})
+	Type(e, "HELLOWORLD")
+	e.Reset()
+	assert.Equal([]byte("AAA"), e.RotorPositions())
+}
+
+func TestParseSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	state, err := ParseSettings("B I II III / 02 21 12 / A B L / AB CD EF")
+	assert.NoError(err)
+	assert.Equal(State{
+		Reflector: Component{Name: "B"}, Rotors: []Component{{Name: "I"}, {Name: "II"}, {Name: "III"}},
+		RingSettings: "BUL", Positions: "ABL", PlugPairs: []string{"AB", "CD", "EF"},
+	}, state)
+	assert.Equal("B I II III / 02 21 12 / ABL / AB CD EF", state.String())
+
+	// Ring settings can be keys too, and the plug pairs are optional.
+	state, err = ParseSettings("B I II III/BUL/ABL")
+	assert.NoError(err)
+	assert.Equal("BUL", state.RingSettings)
+	assert.Nil(state.PlugPairs)
+	assert.Equal("B I II III / 02 21 12 / ABL", state.String())
+
+	// The Enigma G's reflector position comes last, and the Enigma Z's
+	// settings are digits.
+	g := MakeExampleG()
+	g.SetReflectorPosition('K')
+	state, _ = g.SaveState()
+	assert.Equal("G G-I G-II G-III / 01 01 01 / AAA / / K", state.String())
+	parsed, err := ParseSettings(state.String())
+	assert.NoError(err)
+	assert.Equal(state, parsed)
+	z := NewZ()
+	z.InstallRotors([]Rotor{Rotors["Z-I"], Rotors["Z-II"], Rotors["Z-III"]})
+	z.InstallReflector(Reflectors["Z"])
+	z.SetRingSettings([]byte("135"))
+	state, _ = z.SaveState()
+	parsed, err = ParseSettings(state.String())
+	assert.NoError(err)
+	assert.Equal(state, parsed)
+
+	_, err = ParseSettings("B I II III / 01 01 01")
+	assert.Error(err, "The positions are missing")
+	_, err = ParseSettings("B I II III / 01 01 27 / AAA")
+	assert.True(errors.Is(err, ErrBadLetter), "There is no ring setting 27")
+}
+
+func TestOnStep(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	e.SetRotorPositions([]byte("ADU"))
+	var steps []string
+	e.OnStep(func(positions []byte) { steps = append(steps, string(positions)) })
+	Type(e, "AAA")
+	assert.Equal([]string{"ADV", "AEW", "BFX"}, steps, "Every step should be reported, double step included")
+
+	// Loading a state keeps the observer.
+	state, err := e.SaveState()
+	assert.NoError(err)
+	assert.NoError(e.LoadState(state))
+	Type(e, "A")
+	assert.Len(steps, 4, "LoadState should keep the observer")
+	data, err := json.Marshal(e)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, e))
+	Type(e, "A")
+	assert.Len(steps, 5, "UnmarshalJSON should keep the observer")
+
+	// Copies don't report, and the observer can be removed.
+	Type(e.Clone(), "AAA")
+	e.OnStep(nil)
+	Type(e, "AAA")
+	assert.Len(steps, 5)
+}
+
+func TestGetters(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	e.SetRingSettings([]byte("BUL"))
+	plugboard, _ := MakePlugboard([]Pair{{'Q', 'A'}, {'E', 'B'}})
+	e.SetPlugboard(plugboard)
+	assert.Equal(Reflectors["B"], e.Reflector())
+	assert.Equal([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]}, e.Rotors())
+	assert.Equal([]byte("BUL"), e.RingSettings())
+	assert.Equal([]string{"AQ", "BE"}, e.PlugPairs())
+
+	// The results are copies.
+	e.Rotors()[0] = Rotors["V"]
+	assert.Equal(Rotors["I"], e.Rotors()[0])
+
+	empty := New()
+	assert.Equal(Reflector{}, empty.Reflector())
+	assert.Empty(empty.Rotors())
+	assert.Empty(empty.PlugPairs())
+}
+
+func TestReplaceRotor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	e.SetRingSettings([]byte("BUL"))
+	e.SetRotorPositions([]byte("QEV"))
+	Type(e, "HELLO")
+	positions := e.RotorPositions()
+	assert.NoError(e.ReplaceRotor(1, Rotors["IV"]))
+	assert.Equal(positions, e.RotorPositions(), "The positions should be kept")
+
+	// The result is the same as setting up the new wheel order from scratch.
+	want := MakeExampleEnigma(t)
+	want.InstallRotors([]Rotor{Rotors["I"], Rotors["IV"], Rotors["III"]})
+	want.SetRingSettings([]byte("BUL"))
+	want.SetRotorPositions(positions)
+	assert.Equal(Type(want, "WORLD"), Type(e, "WORLD"))
+
+	assert.True(errors.Is(e.ReplaceRotor(3, Rotors["V"]), ErrWrongRotorCount), "There is no slot 3")
+	assert.True(errors.Is(e.ReplaceRotor(0, Rotors["Z-I"]), ErrInvalidRotor), "Rotor Z-I has digits")
+}
+
+func TestSynchronized(t *testing.T) {
+	assert := assert.New(t)
+
+	var e Enigma = Synchronized(MakeExampleEnigma(t))
+	want := Type(MakeExampleEnigma(t), strings.Repeat("A", 400))
+
+	// Concurrent key presses each turn the rotors once, so together they
+	// light the same lamps as typing one after another, in some order.
+	var wg sync.WaitGroup
+	lamps := make(chan byte, 400)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				lamps <- e.KeyPress('A')
+			}
+		}()
+	}
+	wg.Wait()
+	close(lamps)
+	counts := make(map[byte]int)
+	for lamp := range lamps {
+		counts[lamp]++
+	}
+	assert.Equal(letterCounts(want), counts)
+
+	// Do types a whole message without interleaving.
+	s := e.(*SynchronizedEnigma)
+	results := make(chan string, 4)
+	for i := 0; i < 4; i++ {
+		go s.Do(func(e Enigma) {
+			e.SetRotorPositions([]byte("QEV"))
+			results <- Type(e, "HELLOWORLD")
+		})
+	}
+	first := <-results
+	for i := 1; i < 4; i++ {
+		assert.Equal(first, <-results)
+	}
+}
+
+// letterCounts counts the letters in `s`.
+func letterCounts(s string) map[byte]int {
+	counts := make(map[byte]int)
+	for i := range s {
+		counts[s[i]]++
+	}
+	return counts
+}
+
+func TestConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	plugboard, _ := MakePlugboard([]Pair{{'Q', 'A'}})
+	e.SetPlugboard(plugboard)
+	config, err := NewConfig(e)
+	assert.NoError(err)
+	want := Type(e, "HELLO")
+
+	// Machines from a Config are independent of it and of each other.
+	e.SetRingSettings([]byte("BBB"))
+	a, err := config.NewMachine([]byte("AAA"))
+	assert.NoError(err)
+	b, _ := config.NewMachine([]byte("AAA"))
+	assert.Equal(want, Type(a, "HELLO"), "Changing the original should not affect the Config")
+	assert.Equal(want, Type(b, "HELLO"), "Typing on one machine should not affect another")
+	a.SetPlugboard(Plugboard{})
+	c, _ := config.NewMachine([]byte("AAA"))
+	assert.Equal(want, Type(c, "HELLO"))
+
+	_, err = config.NewMachine([]byte("AA"))
+	assert.True(errors.Is(err, ErrWrongRotorCount))
+	_, err = Config{}.NewMachine([]byte("AAA"))
+	assert.Error(err, "An empty Config has no machine")
+
+	// Key sheets have Configs too.
+	key := KeySheet{Reflector: "B", Rotors: []string{"I", "II", "III"}, RingSettings: []byte("AAA"),
+		PlugPairs: []string{"AQ"}}
+	config, err = key.Config("I")
+	assert.NoError(err)
+	d, _ := config.NewMachine([]byte("AAA"))
+	assert.Equal(want, Type(d, "HELLO"))
+
+	// Synchronized machines have Configs too, but other implementations don't.
+	synchronized := Synchronized(MakeExampleEnigma(t))
+	synchronized.SetPlugboard(plugboard)
+	config, err = NewConfig(synchronized)
+	assert.NoError(err)
+	f, _ := config.NewMachine([]byte("AAA"))
+	assert.Equal(want, Type(f, "HELLO"))
+	_, err = NewConfig(struct{ Enigma }{e})
+	assert.Error(err, "Only machines made by this package have Configs")
+
+	// Configs record where their fields came from.
+	state, err := config.State()
+	assert.NoError(err)
+	assert.Equal("B I II III / 01 01 01 / AAA / AQ", state.String())
+	assert.Equal("", config.Source(RotorsField))
+	sourced := config.WithSource(RotorsField, "--rotors").WithSource(PlugPairsField, "default")
+	assert.Equal("--rotors", sourced.Source(RotorsField))
+	assert.Equal("default", sourced.Source(PlugPairsField))
+	assert.Equal("", config.Source(RotorsField), "WithSource should not change the original")
+	overridden := sourced.WithSource(RotorsField, "--settings")
+	assert.Equal("--settings", overridden.Source(RotorsField))
+	assert.Equal("--rotors", sourced.Source(RotorsField))
+	_, err = Config{}.State()
+	assert.Error(err)
+}
+
+func TestState(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	plugboard, _ := MakePlugboard([]Pair{{'Q', 'A'}, {'E', 'B'}})
+	e.SetPlugboard(plugboard)
+	e.SetRingSettings([]byte("BUL"))
+	Type(e, "HELLO")
+	state, err := e.SaveState()
+	assert.NoError(err)
+	assert.Equal(State{
+		Reflector: Component{Name: "B"}, Rotors: []Component{{Name: "I"}, {Name: "II"}, {Name: "III"}},
+		RingSettings: "BUL",
+		PlugPairs:    []string{"AQ", "BE"}, Positions: "AAF",
+	}, state)
+
+	// A machine loaded with the state continues where the other left off.
+	loaded := New()
+	assert.NoError(loaded.LoadState(state))
+	assert.Equal(Type(e, "WORLD"), Type(loaded, "WORLD"))
+
+	// States that don't fit change nothing.
+	bad := state
+	bad.Rotors = []Component{{Name: "I"}, {Name: "II"}, {Name: "XX"}}
+	assert.True(errors.Is(loaded.LoadState(bad), ErrInvalidRotor), "Rotor XX does not exist")
+	bad = state
+	bad.Positions = "AA"
+	assert.True(errors.Is(loaded.LoadState(bad), ErrWrongRotorCount))
+	assert.Equal(Type(e, "AGAIN"), Type(loaded, "AGAIN"), "A failed load should change nothing")
+
+	// The Enigma G's reflector position is part of its state.
+	g := MakeExampleG()
+	g.SetReflectorPosition('K')
+	state, err = g.SaveState()
+	assert.NoError(err)
+	assert.Equal("K", state.ReflectorPosition)
+	loadedG := NewG()
+	assert.NoError(loadedG.LoadState(state))
+	assert.Equal(Type(g, "HELLO"), Type(loadedG, "HELLO"))
+
+	// Rotors that aren't wired can't be saved.
+	maps := 0
+	e.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], countingRotor{Rotors["III"], &maps}})
+	_, err = e.SaveState()
+	assert.True(errors.Is(err, ErrInvalidRotor), "Only wired rotors can be saved")
+}
+
+func TestStateJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	// Named components are written by name, and custom ones by their wiring.
+	custom, _ := MakeRotor("BDFHJLCPRTXVZNYEIWGAKMUSQO", 'B', 'M')
+	e := MakeExampleEnigma(t)
+	e.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], *custom})
+	e.SetRotorPositions([]byte("QEV"))
+	data, err := json.Marshal(e)
+	assert.NoError(err)
+	assert.Equal(`{"reflector":"B","rotors":["I","II",{"wiring":"BDFHJLCPRTXVZNYEIWGAKMUSQO","notches":"BM"}],`+
+		`"ringSettings":"AAA","positions":"QEV"}`, string(data))
+
+	loaded := New()
+	assert.NoError(json.Unmarshal(data, loaded))
+	assert.Equal(Type(e, "HELLOWORLD"), Type(loaded, "HELLOWORLD"))
+
+	// Custom components keep their alphabet.
+	z := NewZ()
+	reflector, _ := MakeAlphabetReflector(Digits, "2143658709")
+	z.InstallReflector(*reflector)
+	z.InstallRotors([]Rotor{Rotors["Z-I"], Rotors["Z-II"], Rotors["Z-III"]})
+	state, err := z.SaveState()
+	assert.NoError(err)
+	assert.Equal(Component{Wiring: "2143658709", Alphabet: Digits}, state.Reflector)
+	data, _ = json.Marshal(state)
+	var decoded State
+	assert.NoError(json.Unmarshal(data, &decoded))
+	assert.Equal(state, decoded)
+
+	// Alphabets from JSON are checked before they size a component, so an
+	// over-long one is refused rather than overrunning its contacts.
+	var long []byte
+	for s := byte('!'); len(long) < 70; s++ {
+		long = append(long, s)
+	}
+	data, err = json.Marshal(State{
+		Reflector:    Component{Name: "B"},
+		Rotors:       []Component{{Name: "I"}, {Name: "II"}, {Wiring: string(long), Alphabet: Alphabet(long)}},
+		RingSettings: "AAA",
+		Positions:    "AAA",
+	})
+	assert.NoError(err)
+	assert.ErrorIs(json.Unmarshal(data, New()), ErrInvalidRotor)
+	_, err = MakeAlphabetRotor(Alphabet(long), string(long))
+	assert.ErrorIs(err, ErrInvalidRotor)
+	_, err = MakeAlphabetReflector(Alphabet(long), string(long))
+	assert.ErrorIs(err, ErrInvalidReflector)
 }
 
 func TestPlugboard(t *testing.T) {
 	assert := assert.New(t)
 	enigma := MakeExampleEnigma(t)
-	enigma.SetPlugboard(MakePlugboard([]Pair{{'A', 'B'}, {'C', 'D'}}))
+	plugboard, err := MakePlugboard([]Pair{{'A', 'B'}, {'C', 'D'}})
+	assert.NoError(err)
+	enigma.SetPlugboard(plugboard)
 
 	// The same test as in `TestBasic`, but the plugboard modifies both the input
 	// and the output. The input "AAAAA" becomes "BBBBB", whose output "AJLCS"
@@ -115,9 +619,12 @@ func TestPlugboard(t *testing.T) {
 	assert.Equal("BJLDS", encrypted, "The plugboard had an unexpected effect")
 
 	// Reset the rotor positions for decryption.
-	ResetExampleEnigma(enigma)
+	enigma.Reset()
 	decrypted := Type(enigma, encrypted)
 	assert.Equal(input, decrypted, "Failed to reverse encryption.")
+
+	_, err = MakePlugboard([]Pair{{'A', 'B'}, {'B', 'C'}})
+	assert.Error(err, "A letter can only be in one pair")
 }
 
 func TestRealMessage1(t *testing.T) {
@@ -129,8 +636,10 @@ func TestRealMessage1(t *testing.T) {
 	enigma.InstallReflector(Reflectors["A"])
 	enigma.InstallRotors([]Rotor{Rotors["II"], Rotors["I"], Rotors["III"]})
 	enigma.SetRingSettings([]byte{'X', 'M', 'V'}) // Described as positions 24, 13, 22.
-	enigma.SetPlugboard(MakePlugboard([]Pair{
-		{'A', 'M'}, {'F', 'I'}, {'N', 'V'}, {'P', 'S'}, {'T', 'U'}, {'W', 'Z'}}))
+	plugboard, err := MakePlugboard([]Pair{
+		{'A', 'M'}, {'F', 'I'}, {'N', 'V'}, {'P', 'S'}, {'T', 'U'}, {'W', 'Z'}})
+	assert.NoError(err)
+	enigma.SetPlugboard(plugboard)
 	enigma.SetRotorPositions([]byte{'A', 'B', 'L'}) // Described as "message key".
 
 	encrypted :=
@@ -149,7 +658,9 @@ func TestRealMessage2(t *testing.T) {
 	enigma.InstallReflector(Reflectors["B"]) // Assumed, not explicitly stated.
 	enigma.InstallRotors([]Rotor{Rotors["II"], Rotors["I"], Rotors["V"]})
 	enigma.SetRingSettings([]byte{'A', 'A', 'A'})
-	enigma.SetPlugboard(MakePlugboard([]Pair{{'A', 'B'}, {'I', 'R'}, {'U', 'X'}, {'K', 'P'}}))
+	plugboard, err := MakePlugboard([]Pair{{'A', 'B'}, {'I', 'R'}, {'U', 'X'}, {'K', 'P'}})
+	assert.NoError(err)
+	enigma.SetPlugboard(plugboard)
 	enigma.SetRotorPositions([]byte{'F', 'R', 'A'})
 
 	encrypted := "PCDAONONEBCJBOGLYMEEYGSHRYUBUJHMJOQZLEX"
@@ -157,4 +668,1144 @@ func TestRealMessage2(t *testing.T) {
 	assert.Equal("ANBULMEGRAZGOESTINGSTRENGGEHEIMEMELDUNG", decrypted, "Incorrect decryption")
 }
 
+func TestM4(t *testing.T) {
+	assert := assert.New(t)
+
+	// With the Greek rotor at position A and ring setting A, the thin B and C
+	// reflectors combine with the Beta and Gamma rotors to act just like the
+	// regular B and C reflectors. This let the M4 talk to M3 machines.
+	for greek, thin := range map[string]string{"Beta": "B-thin", "Gamma": "C-thin"} {
+		m3 := MakeExampleEnigma(t)
+		m3.InstallReflector(Reflectors[thin[:1]])
+
+		m4 := New()
+		m4.InstallRotors([]Rotor{Rotors[greek], Rotors["I"], Rotors["II"], Rotors["III"]})
+		m4.SetRingSettings([]byte{'A', 'A', 'A', 'A'})
+		m4.SetRotorPositions([]byte{'A', 'A', 'A', 'A'})
+		m4.InstallReflector(Reflectors[thin])
+
+		input := strings.Repeat("THEQUICKBROWNFOXJUMPSOVERTHELAZYDOG", 30)
+		assert.Equal(Type(m3, input), Type(m4, input), "M4 with %v and %v differs from M3", greek, thin)
+	}
+}
+
+func TestValidateSpindle(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ValidateSpindle(Reflectors["B"], []Rotor{Rotors["I"], Rotors["II"], Rotors["III"]}))
+	assert.NoError(ValidateSpindle(
+		Reflectors["B-thin"], []Rotor{Rotors["Beta"], Rotors["I"], Rotors["II"], Rotors["III"]}))
+
+	assert.Error(ValidateSpindle(
+		Reflectors["B-thin"], []Rotor{Rotors["I"], Rotors["Beta"], Rotors["II"], Rotors["III"]}),
+		"Thin rotors only fit in the leftmost slot")
+	assert.Error(ValidateSpindle(
+		Reflectors["B-thin"], []Rotor{Rotors["Beta"], Rotors["II"], Rotors["III"]}),
+		"Thin rotors only fit in an M4")
+	assert.Error(ValidateSpindle(
+		Reflectors["B"], []Rotor{Rotors["IV"], Rotors["I"], Rotors["II"], Rotors["III"]}),
+		"Regular rotors don't fit in the M4's leftmost slot")
+	assert.Error(ValidateSpindle(
+		Reflectors["B"], []Rotor{Rotors["Beta"], Rotors["I"], Rotors["II"], Rotors["III"]}),
+		"Thin rotors need a thin reflector")
+	assert.Error(ValidateSpindle(Reflectors["C-thin"], []Rotor{Rotors["I"], Rotors["II"], Rotors["III"]}),
+		"Thin reflectors need a thin rotor")
+}
+
+func TestM4Stepping(t *testing.T) {
+	assert := assert.New(t)
+	enig := New()
+	enig.InstallRotors([]Rotor{Rotors["Beta"], Rotors["I"], Rotors["II"], Rotors["III"]})
+	enig.SetRingSettings([]byte{'A', 'A', 'A', 'A'})
+	enig.InstallReflector(Reflectors["B-thin"])
+	e := enig.(*enigma)
+
+	// The same double step sequence as in TestSingleDoubleStep; the Greek rotor
+	// never moves.
+	e.SetRotorPositions([]byte{'A', 'A', 'D', 'V'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'A', 'E', 'W'}, e.RotorPositions(), "The rotor positions are wrong")
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'B', 'F', 'X'}, e.RotorPositions(), "The rotor positions are wrong")
+
+	// Rotor I's notch has no effect, since there's no turning rotor to its left.
+	e.SetRotorPositions([]byte{'A', 'Q', 'A', 'A'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'Q', 'A', 'B'}, e.RotorPositions(), "The rotor positions are wrong")
+}
+
+func MakeExampleG() Enigma {
+	g := NewG()
+	g.InstallRotors([]Rotor{Rotors["G-I"], Rotors["G-II"], Rotors["G-III"]})
+	g.SetRingSettings([]byte{'A', 'A', 'A'})
+	g.InstallReflector(Reflectors["G"])
+	return g
+}
+
+func TestGStepping(t *testing.T) {
+	assert := assert.New(t)
+	e := MakeExampleG().(*enigma)
+
+	// G-III turns G-II when leaving its U notch.
+	e.SetRotorPositions([]byte{'D', 'B', 'U'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'D', 'C', 'V'}, e.RotorPositions(), "The rotor positions are wrong")
+
+	// G-II is now in its C notch, but there's no double step.
+	e.KeyPress('A')
+	assert.Equal([]byte{'D', 'C', 'W'}, e.RotorPositions(), "The rotor positions are wrong")
+	e.KeyPress('A')
+	assert.Equal([]byte{'E', 'D', 'X'}, e.RotorPositions(), "The rotor positions are wrong")
+	assert.Equal(uint8(0), e.reflectorRotation, "The reflector should not have turned")
+
+	// When all rotors are in a notch, the reflector turns too.
+	e.SetRotorPositions([]byte{'C', 'Q', 'U'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'D', 'R', 'V'}, e.RotorPositions(), "The rotor positions are wrong")
+	assert.Equal(uint8(1), e.reflectorRotation, "The reflector should have turned")
+
+	// The reflector position is part of the machine's state, and of traces.
+	assert.Equal(byte('B'), e.ReflectorPosition())
+	e.SetRotorPositions([]byte{'C', 'Q', 'U'})
+	trace := NewTrace(3)
+	e.TraceKeyPress('A', trace)
+	assert.Equal(byte('C'), trace.ReflectorPosition, "Traces show the position after turning")
+	data, err := json.Marshal(trace)
+	assert.NoError(err)
+	assert.Contains(string(data), `"positions":"DRV","reflectorPosition":"C"`)
+	var read Trace
+	assert.NoError(json.Unmarshal(data, &read))
+	assert.Equal(*trace, read)
+	var dot bytes.Buffer
+	assert.NoError(WriteTraceDOT(&dot, trace))
+	assert.Contains(dot.String(), `[label="reflector at C"]`)
+	state, err := e.SaveState()
+	assert.NoError(err)
+	assert.Equal("C", state.ReflectorPosition)
+	assert.Equal(byte(0), MakeExampleEnigma(t).ReflectorPosition(), "The I's reflector is fixed")
+}
+
+// allStepping turns every rotor on every key press.
+type allStepping struct{}
+
+func (allStepping) Step(rotors []WheelState, turns []bool) bool {
+	for i := range turns {
+		turns[i] = true
+	}
+	return false
+}
+
+func TestSteppingMechanism(t *testing.T) {
+	assert := assert.New(t)
+	enig := NewWithStepping(allStepping{})
+	enig.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]})
+	enig.SetRingSettings([]byte{'A', 'A', 'A'})
+	enig.InstallReflector(Reflectors["B"])
+	e := enig.(*enigma)
+
+	e.SetRotorPositions([]byte{'A', 'B', 'C'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'B', 'C', 'D'}, e.RotorPositions(), "The rotor positions are wrong")
+
+	// Gear stepping on an otherwise regular machine has no double step.
+	enig = NewWithStepping(GearStepping{})
+	enig.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]})
+	e = enig.(*enigma)
+	e.SetRotorPositions([]byte{'A', 'D', 'V'})
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'E', 'W'}, e.RotorPositions(), "The rotor positions are wrong")
+	e.KeyPress('A')
+	assert.Equal([]byte{'A', 'E', 'X'}, e.RotorPositions(), "The rotor positions are wrong")
+}
+
+func TestG(t *testing.T) {
+	assert := assert.New(t)
+	g := MakeExampleG()
+
+	input := strings.Repeat("THEQUICKBROWNFOXJUMPSOVERTHELAZYDOG", 30)
+	g.SetReflectorPosition('K')
+	g.SetRotorPositions([]byte{'C', 'Q', 'U'})
+	encrypted := Type(g, input)
+	g.Reset()
+	assert.Equal(input, Type(g, encrypted), "Failed to reverse encryption.")
+
+	// The reflector position is part of the key.
+	g.SetReflectorPosition('L')
+	g.SetRotorPositions([]byte{'C', 'Q', 'U'})
+	assert.NotEqual(encrypted, Type(g, input), "The reflector position had no effect")
+}
+
+func TestK(t *testing.T) {
+	assert := assert.New(t)
+	k := NewK()
+	k.InstallRotors([]Rotor{Rotors["K-I"], Rotors["K-II"], Rotors["K-III"]})
+	k.SetRingSettings([]byte{'A', 'A', 'A'})
+	k.InstallReflector(Reflectors["K"])
+	e := k.(*enigma)
+
+	// The K double steps like the Enigma I, and its reflector never turns.
+	k.SetReflectorPosition('F')
+	k.SetRotorPositions([]byte{'A', 'D', 'N'})
+	k.KeyPress('A')
+	assert.Equal([]byte{'A', 'E', 'O'}, e.RotorPositions(), "The rotor positions are wrong")
+	k.KeyPress('A')
+	assert.Equal([]byte{'B', 'F', 'P'}, e.RotorPositions(), "The rotor positions are wrong")
+	assert.Equal(uint8('F'-'A'), e.reflectorRotation, "The reflector should not have turned")
+
+	input := strings.Repeat("THEQUICKBROWNFOXJUMPSOVERTHELAZYDOG", 30)
+	k.SetRotorPositions([]byte{'A', 'D', 'N'})
+	encrypted := Type(k, input)
+	k.Reset()
+	assert.Equal(input, Type(k, encrypted), "Failed to reverse encryption.")
+
+	// The Swiss rotors are wired differently. These known answers were worked
+	// out from the published wiring tables independently of this package.
+	// TODO: test a published Swiss-K sample message, with its key, as well.
+	swiss, err := NewModel("Swiss-K", "K", []string{"SK-I", "SK-II", "SK-III"})
+	assert.NoError(err)
+	assert.NoError(swiss.SetRingSettings([]byte("AAA")))
+	assert.Equal("FWFVQ", Type(swiss, "HELLO"))
+	assert.NoError(swiss.SetRingSettings([]byte("BCD")))
+	assert.NoError(swiss.SetRotorPositions([]byte("ADN")))
+	assert.NoError(swiss.SetReflectorPosition('F'))
+	assert.Equal("FBWFFNFVPE", Type(swiss, "HELLOWORLD"))
+	swiss.Reset()
+	assert.Equal("HELLOWORLD", Type(swiss, "FBWFFNFVPE"), "Failed to reverse encryption.")
+}
+
+func TestNorenigma(t *testing.T) {
+	assert := assert.New(t)
+
+	// Known answers for the rewired rotors and reflector, worked out from the
+	// published wiring tables independently of this package.
+	e, err := NewModel("Norenigma", "N", []string{"N-I", "N-II", "N-III"})
+	assert.NoError(err)
+	assert.NoError(e.SetRingSettings([]byte("AAA")))
+	assert.Equal("FKMGN", Type(e, "HELLO"))
+
+	e, err = NewModel("Norenigma", "N", []string{"N-V", "N-IV", "N-III"})
+	assert.NoError(err)
+	assert.NoError(e.SetRingSettings([]byte("BCD")))
+	assert.NoError(e.SetRotorPositions([]byte("QEV")))
+	assert.Equal("KLSHJZJFOQ", Type(e, "HELLOWORLD"), "N-IV and N-V are wired wrong")
+
+	_, err = NewModel("Norenigma", "B", []string{"N-I", "N-II", "N-III"})
+	assert.Error(err, "The Norenigma only takes its own reflector")
+}
+
+func TestT(t *testing.T) {
+	assert := assert.New(t)
+	tirpitz := NewT()
+	tirpitz.InstallRotors([]Rotor{Rotors["T-VIII"], Rotors["T-II"], Rotors["T-V"]})
+	tirpitz.SetRingSettings([]byte{'C', 'A', 'R'})
+	tirpitz.InstallReflector(Reflectors["T"])
+	tirpitz.SetReflectorPosition('M')
+
+	input := strings.Repeat("THEQUICKBROWNFOXJUMPSOVERTHELAZYDOG", 30)
+	tirpitz.SetRotorPositions([]byte{'X', 'Y', 'Z'})
+	encrypted := Type(tirpitz, input)
+	tirpitz.Reset()
+	assert.Equal(input, Type(tirpitz, encrypted), "Failed to reverse encryption.")
+
+	// The entry wheel is part of the signal path.
+	assert.NoError(tirpitz.InstallEntryWheel(EntryWheels["ABC"]))
+	tirpitz.SetRotorPositions([]byte{'X', 'Y', 'Z'})
+	assert.NotEqual(encrypted, Type(tirpitz, input), "The entry wheel had no effect")
+	assert.NoError(tirpitz.InstallEntryWheel(EntryWheels["T"]))
+	tirpitz.SetRotorPositions([]byte{'X', 'Y', 'Z'})
+	assert.Equal(encrypted, Type(tirpitz, input), "The entry wheel was not replaced")
+}
+
+func TestZ(t *testing.T) {
+	assert := assert.New(t)
+	z := NewZ()
+	z.InstallRotors([]Rotor{Rotors["Z-I"], Rotors["Z-II"], Rotors["Z-III"]})
+	z.SetRingSettings([]byte{'1', '1', '1'})
+	z.InstallReflector(Reflectors["Z"])
+	e := z.(*enigma)
+
+	// The rotors have 10 positions, and turn over going from 9 to 0.
+	z.SetRotorPositions([]byte{'1', '1', '8'})
+	z.KeyPress('5')
+	assert.Equal([]byte{'1', '1', '9'}, e.RotorPositions(), "The rotor positions are wrong")
+	z.KeyPress('5')
+	assert.Equal([]byte{'1', '2', '0'}, e.RotorPositions(), "The rotor positions are wrong")
+
+	input := strings.Repeat("31415 92653 58979 ", 20)
+	z.SetRotorPositions([]byte{'4', '0', '7'})
+	encrypted := TypeDigits(z, input)
+	assert.Len(strings.Fields(encrypted), 60, "The groups were not kept")
+	z.Reset()
+	assert.Equal(input, TypeDigits(z, encrypted), "Failed to reverse encryption.")
+
+	assert.Error(ValidateSpindle(Reflectors["B"], []Rotor{Rotors["Z-I"], Rotors["Z-II"], Rotors["Z-III"]}),
+		"Rotors with 10 contacts don't fit with a 26-contact reflector")
+	assert.NoError(ValidateSpindle(Reflectors["Z"], []Rotor{Rotors["Z-I"], Rotors["Z-II"], Rotors["Z-III"]}))
+}
+
+func TestLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	qwertzu := Layouts["QWERTZU"]
+	assert.NoError(ValidateLayout(qwertzu))
+	row, column, ok := qwertzu.Position('P')
+	assert.True(ok)
+	assert.Equal([]int{2, 0}, []int{row, column}, "P should be first on the bottom row")
+	_, _, ok = qwertzu.Position('7')
+	assert.False(ok, "The keyboard has no digits")
+
+	abc := Layout{Rows: []string{"ABCDEFGHI", "JKLMNOPQ", "RSTUVWXYZ"}}
+	assert.NoError(RegisterLayout("ABC", abc))
+	assert.Contains(LayoutNames(), "ABC")
+	assert.Error(RegisterLayout("ABC", abc), "Layouts can't be replaced")
+	assert.Error(RegisterLayout("Short", Layout{Rows: []string{"ABC"}}), "Layouts need every letter")
+	delete(Layouts, "ABC")
+}
+
+func TestPhonetic(t *testing.T) {
+	assert := assert.New(t)
+
+	spelled := SpellPhonetic("GCDSE AHU")
+	assert.Equal("Gustav Cäsar Dora Siegfried Emil / Anton Heinrich Ulrich", spelled, "Unexpected spelling")
+	text, err := ParsePhonetic(spelled)
+	assert.NoError(err)
+	assert.Equal("GCDSE AHU", text, "Failed to reverse spelling")
+
+	text, err = ParsePhonetic("caesar NORDPOL / xanthippe")
+	assert.NoError(err)
+	assert.Equal("CN X", text, "Unexpected parse")
+
+	_, err = ParsePhonetic("Anton Bertha")
+	assert.Error(err, "Words outside the alphabet should be rejected")
+}
+
+func TestModels(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range ModelNames() {
+		m := Models[name]
+		assert.NoError(m.Validate(m.DefaultReflector, m.DefaultRotors), "Bad defaults for %v", name)
+	}
+
+	e, err := NewModel("M3", "B", []string{"I", "II", "III"})
+	assert.NoError(err)
+	e.SetRingSettings([]byte{'A', 'A', 'A'})
+	e.SetRotorPositions([]byte{'A', 'A', 'A'})
+	assert.Equal("BDZGO", Type(e, "AAAAA"), "An M3 should work like an Enigma I")
+
+	_, err = NewModel("M3", "B", []string{"I", "II", "VI"})
+	assert.NoError(err)
+	_, err = NewModel("I", "B", []string{"I", "II", "VI"})
+	assert.Error(err, "Rotor VI was never issued for the Enigma I")
+	_, err = NewModel("M4", "B-thin", []string{"I", "II", "Beta", "III"})
+	assert.Error(err, "Beta only fits in the leftmost slot")
+	_, err = NewModel("M4", "B", []string{"Beta", "I", "II", "III"})
+	assert.Error(err, "The M4 needs a thin reflector")
+	_, err = NewModel("I", "B", []string{"I", "II"})
+	assert.Error(err, "The Enigma I takes 3 rotors")
+	_, err = NewModel("X", "B", []string{"I", "II", "III"})
+	assert.Error(err, "There is no model X")
+}
+
+func TestCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	info, err := LookupRotor("VI")
+	assert.NoError(err)
+	assert.Equal("ZM", info.Notches)
+	assert.Equal("Enigma M3 and M4", info.Family)
+	_, err = LookupRotor("IX")
+	assert.Error(err, "There is no rotor IX")
+	reflector, err := LookupReflector("B-thin")
+	assert.NoError(err)
+	assert.True(reflector.Thin)
+
+	rotors, err := ListRotors("M4")
+	assert.NoError(err)
+	assert.Equal("Beta", rotors[0].Name)
+	assert.Equal(len(Models["M4"].Rotors), len(rotors))
+	all, err := ListRotors("")
+	assert.NoError(err)
+	assert.Equal(len(Rotors), len(all))
+	_, err = ListReflectors("M5")
+	assert.Error(err, "There is no M5")
+
+	// Every model's components are in the catalog.
+	for _, name := range ModelNames() {
+		_, err = ListRotors(name)
+		assert.NoError(err, "Model %v", name)
+		_, err = ListReflectors(name)
+		assert.NoError(err, "Model %v", name)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+
+	// A copy of rotor I, and reflector B.
+	assert.NoError(RegisterRotor("Test-I", "EKMFLGDQVZNTOWYHXUSPAIBRCJ", 'Q'))
+	assert.NoError(RegisterReflector("Test-B", "YRUHQSLDPXNGOKMIEBFZCWVJAT"))
+	assert.Error(RegisterRotor("Test-I", "EKMFLGDQVZNTOWYHXUSPAIBRCJ", 'Q'), "Test-I already exists")
+	assert.Error(RegisterRotor("III", "EKMFLGDQVZNTOWYHXUSPAIBRCJ"), "III already exists")
+	assert.Error(RegisterReflector("Test-Bad", "EKMFLGDQVZNTOWYHXUSPAIBRCJ"), "That's no reflector")
+	assert.Error(RegisterRotor("", "EKMFLGDQVZNTOWYHXUSPAIBRCJ"), "Components need a name")
+
+	info, err := LookupRotor("Test-I")
+	assert.NoError(err)
+	assert.Equal(CustomFamily, info.Family)
+	assert.Equal("Q", info.Notches)
+
+	e, err := NewModel("I", "Test-B", []string{"Test-I", "II", "III"})
+	assert.NoError(err)
+	assert.Equal("BDZGO", Type(e, "AAAAA"), "The custom components behave differently")
+	_, err = NewModel("Z", "Z", []string{"Test-I", "Z-II", "Z-III"})
+	assert.Error(err, "Lettered rotors don't fit the Enigma Z")
+	rotors, err := ListRotors("M3")
+	assert.NoError(err)
+	assert.Equal("Test-I", rotors[len(rotors)-1].Name)
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := MakeRotor("JPGVOUMFYQBENHZRDKASXLICTT", 'Z')
+	assert.True(errors.Is(err, ErrInvalidRotor), err)
+	err = RegisterReflector("Test-Broken", "EKMFLGDQVZNTOWYHXUSPAIBRCJ")
+	assert.True(errors.Is(err, ErrInvalidReflector), "Wrapped errors should still match: %v", err)
+	_, err = MakePlugboard([]Pair{{'A', 'B'}, {'B', 'C'}})
+	assert.True(errors.Is(err, ErrPlugConflict), err)
+	_, err = MakePlugboard([]Pair{{'A', '1'}})
+	assert.True(errors.Is(err, ErrBadLetter), err)
+	_, err = NewModel("I", "B", []string{"I", "II"})
+	assert.True(errors.Is(err, ErrWrongRotorCount), err)
+	assert.False(errors.Is(err, ErrInvalidRotor), err)
+}
+
+func TestSettingsValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	enigma := MakeExampleEnigma(t)
+	err := enigma.SetRingSettings([]byte{'B', 'B'})
+	assert.True(errors.Is(err, ErrWrongRotorCount), err)
+	err = enigma.SetRotorPositions([]byte{'A', 'A', 'a'})
+	assert.True(errors.Is(err, ErrBadLetter), err)
+	assert.Equal("BDZGO", Type(enigma, "AAAAA"), "Rejected settings should change nothing")
+
+	err = enigma.InstallRotors([]Rotor{Rotors["I"], Rotors["Z-II"], Rotors["III"]})
+	assert.True(errors.Is(err, ErrInvalidRotor), err)
+	err = enigma.SetReflectorPosition('1')
+	assert.True(errors.Is(err, ErrBadLetter), err)
+
+	_, err = enigma.TryKeyPress('1')
+	assert.True(errors.Is(err, ErrBadLetter), err)
+	bare := New()
+	assert.NoError(bare.InstallRotors([]Rotor{Rotors["I"], Rotors["II"], Rotors["III"]}))
+	_, err = bare.TryKeyPress('A')
+	assert.True(errors.Is(err, ErrMissingReflector), err)
+	bare.InstallReflector(Reflectors["Z"])
+	_, err = bare.TryKeyPress('A')
+	assert.True(errors.Is(err, ErrInvalidReflector), err)
+	bare.InstallReflector(Reflectors["B"])
+	light, err := bare.TryKeyPress('A')
+	assert.NoError(err)
+	assert.Equal(byte('B'), light)
+}
+
+func TestTypeChecked(t *testing.T) {
+	assert := assert.New(t)
+
+	enigma := MakeExampleEnigma(t)
+	light, err := enigma.TryKeyPress('a')
+	assert.NoError(err)
+	assert.Equal(byte('B'), light, "Lowercase letters should be typed as uppercase")
+
+	enigma.Reset()
+	lights, err := TypeChecked(enigma, "aaA a", 0)
+	assert.NoError(err)
+	assert.Equal("BDZ G", lights)
+	enigma.Reset()
+	lights, err = TypeChecked(enigma, "AA!", 0)
+	assert.True(errors.Is(err, ErrBadLetter), err)
+	assert.Equal("BD", lights, "The lights before the bad character should be returned")
+
+	// With a substitute, punctuation is typed as 'X'.
+	enigma.Reset()
+	withX := Type(enigma, "ANXKOELN")
+	enigma.Reset()
+	lights, err = TypeChecked(enigma, "an,koeln", 'X')
+	assert.NoError(err)
+	assert.Equal(withX, lights)
+}
+
+func TestClone(t *testing.T) {
+	assert := assert.New(t)
+
+	var plugboard Plugboard
+	plugboard.AddPlugPair('A', 'Z')
+	enigma := MakeExampleEnigma(t)
+	enigma.SetPlugboard(plugboard)
+	Type(enigma, "AAA")
+
+	// Clones continue from where the original is, but turn on their own.
+	clone, state := enigma.Clone(), enigma.CloneState()
+	want := Type(enigma, "HELLO")
+	assert.Equal(want, Type(clone, "HELLO"))
+	assert.Equal(want, Type(state, "HELLO"))
+	assert.Equal(Type(clone, "WORLD"), Type(state, "WORLD"))
+
+	// Only Clone copies the plugboard.
+	clone, state = enigma.Clone(), enigma.CloneState()
+	plugboard.AddPlugPair('B', 'Y')
+	assert.Equal(Type(enigma.Clone(), "BBB"), Type(state, "BBB"))
+	assert.NotEqual(Type(enigma, "BBB"), Type(clone, "BBB"))
+
+	// Clones can be used concurrently.
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func(e Enigma) { done <- Type(e, "HELLO") }(enigma.CloneState())
+	}
+	want = Type(enigma, "HELLO")
+	for i := 0; i < 4; i++ {
+		assert.Equal(want, <-done)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	e := MakeExampleEnigma(t)
+	trace := NewTrace(3)
+	assert.Equal(byte('B'), e.TraceKeyPress('A', trace))
+	assert.Equal(byte('A'), trace.Key)
+	assert.Equal(byte('B'), trace.Lamp)
+	assert.Equal([]byte("AAB"), trace.Positions, "The rotors turn before the signal passes")
+	var path []byte
+	var slots []int
+	for _, step := range trace.Steps {
+		path = append(path, step.Signal)
+		if step.Stage == RotorStage {
+			slots = append(slots, step.Slot)
+		}
+	}
+	assert.Equal("AACDFSSEBBB", string(path))
+	assert.Equal([]int{2, 1, 0, 0, 1, 2}, slots)
+
+	// Tracing gives the same lamps as typing, and reusing a trace doesn't
+	// allocate.
+	e.Reset()
+	want := Type(e, "HELLOWORLD")
+	e.Reset()
+	for i, k := range []byte("HELLOWORLD") {
+		assert.Equal(want[i], e.TraceKeyPress(k, trace))
+	}
+	allocs := testing.AllocsPerRun(100, func() { e.TraceKeyPress('A', trace) })
+	assert.Equal(0.0, allocs, "Tracing with a reused trace should not allocate")
+	traced := func(*Trace) {}
+	for _, machine := range []Enigma{e, Synchronized(e)} {
+		short := testing.AllocsPerRun(100, func() {
+			TypeTraced(machine, strings.Repeat("hello, world", 5), 'X', traced)
+		})
+		long := testing.AllocsPerRun(100, func() {
+			TypeTraced(machine, strings.Repeat("hello, world", 50), 'X', traced)
+		})
+		assert.Equal(short, long, "TypeTraced should not allocate for every key")
+	}
+
+	// TypeTraced types like TypeChecked, tracing every key press.
+	e.Reset()
+	var lamps []byte
+	lights, err := TypeTraced(e, "hello, world", 'X', func(trace *Trace) {
+		lamps = append(lamps, trace.Lamp)
+	})
+	assert.NoError(err)
+	e.Reset()
+	want, _ = TypeChecked(e, "hello, world", 'X')
+	assert.Equal(want, lights)
+	assert.Equal(strings.Replace(want, " ", "", -1), string(lamps))
+	e.Reset()
+	_, err = TypeTraced(e, "AB!", 0, func(*Trace) {})
+	assert.ErrorIs(err, ErrBadLetter)
+	assert.Equal([]byte("AAC"), e.RotorPositions(), "Only the keys typed should turn the rotors")
+
+	// Traces can be written as JSON and read back.
+	e.Reset()
+	e.TraceKeyPress('A', trace)
+	data, err := json.Marshal(trace)
+	assert.NoError(err)
+	assert.Contains(string(data), `"key":"A","lamp":"B","positions":"AAB"`)
+	assert.Contains(string(data), `{"stage":"plugboard","signal":"A"},{"stage":"entry wheel","signal":"A"},`+
+		`{"stage":"rotor","slot":2,"signal":"C"}`)
+	var read Trace
+	assert.NoError(json.Unmarshal(data, &read))
+	assert.Equal(*trace, read)
+	assert.Error(json.Unmarshal([]byte(`{"key": "AB", "lamp": "B"}`), &read))
+
+	// A trace can be drawn as a graph, from the key to the lamp.
+	var dot bytes.Buffer
+	assert.NoError(WriteTraceDOT(&dot, trace))
+	assert.Contains(dot.String(), `key [label="key A", shape=circle];`)
+	assert.Contains(dot.String(), `n2 [label="rotor 3 at B"];`)
+	assert.Contains(dot.String(), `n1 -> n2 [label="A"];`+"\n\tn2 -> n3 [label=\"C\"];")
+	assert.True(strings.HasSuffix(dot.String(), "\tn10 -> lamp [label=\"B\"];\n}\n"))
+
+	// A message's traces can be written as an HTML page.
+	var page bytes.Buffer
+	assert.NoError(WriteTraceHTML(&page, "<A>", []Trace{*trace}))
+	assert.Contains(page.String(), "<title>&lt;A&gt;</title>", "The title should be escaped")
+	assert.Contains(page.String(), `const traces = [{"key":"A","lamp":"B","positions":"AAB",`)
+	page.Reset()
+	assert.NoError(WriteTraceHTML(&page, "Nothing", nil))
+	assert.Contains(page.String(), "const traces = [];")
+}
+
+func TestLoadComponents(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(LoadComponents(strings.NewReader(`{
+		"rotors": [{"name": "File-I", "wiring": "EKMFLGDQVZNTOWYHXUSPAIBRCJ", "notches": "Q"}],
+		"reflectors": [{"name": "File-B", "wiring": "YRUHQSLDPXNGOKMIEBFZCWVJAT"}]
+	}`)))
+	e, err := NewModel("I", "File-B", []string{"File-I", "II", "III"})
+	assert.NoError(err)
+	assert.Equal("BDZGO", Type(e, "AAAAA"), "The loaded components behave differently")
+
+	// Nothing is registered from a file with an error in it.
+	assert.Error(LoadComponents(strings.NewReader(`{
+		"rotors": [{"name": "File-II", "wiring": "AJDKSIRUXBLHWTMCQGZNPYFVOE", "notches": "E"}],
+		"reflectors": [{"name": "File-Bad", "wiring": "EKMFLGDQVZNTOWYHXUSPAIBRCJ"}]
+	}`)), "That's no reflector")
+	_, err = LookupRotor("File-II")
+	assert.Error(err, "File-II should not have been registered")
+
+	assert.Error(LoadComponents(strings.NewReader(
+		`{"rotors": [{"name": "I", "wiring": "AJDKSIRUXBLHWTMCQGZNPYFVOE"}]}`)), "Rotor I already exists")
+	assert.Error(LoadComponents(strings.NewReader(`{"rotor": []}`)), "Unknown fields should be rejected")
+	assert.Error(LoadComponents(strings.NewReader(`{"rotors": [`)), "Broken JSON should be rejected")
+}
+
+func TestNavalIndicator(t *testing.T) {
+	assert := assert.New(t)
+
+	var table BigramTable
+	for _, pair := range [][2]string{
+		{"XK", "PA"}, {"RY", "QC"}, {"TZ", "WN"}, {"LQ", "DB"},
+	} {
+		assert.NoError(table.AddPair(pair[0], pair[1]))
+	}
+	assert.Error(table.AddPair("XK", "MM"), "XK is already mapped")
+	assert.Error(table.AddPair("MM", "MM"), "Bigrams can't map to themselves")
+
+	// Top row XRTL, bottom row KYZQ.
+	groups, err := EncodeNavalIndicator(&table, "RTL", "KYZ", [2]byte{'X', 'Q'})
+	assert.NoError(err)
+	assert.Equal([2]string{"PQWD", "ACNB"}, groups, "Unexpected indicator")
+
+	kenngruppe, key, err := DecodeNavalIndicator(&table, groups)
+	assert.NoError(err)
+	assert.Equal("RTL", kenngruppe)
+	assert.Equal("KYZ", key)
+
+	_, err = EncodeNavalIndicator(&table, "RTL", "KYA", [2]byte{'X', 'Q'})
+	assert.Error(err, "ZA... is not in the table")
+
+	var book Kenngruppenbuch
+	assert.NoError(book.AddGroup("RTL", "Triton"))
+	assert.NoError(book.AddGroup("ABC", "Triton"))
+	assert.Error(book.AddGroup("RTL", "Hydra"), "RTL already identifies Triton")
+	net, err := book.Net(kenngruppe)
+	assert.NoError(err)
+	assert.Equal("Triton", net)
+	assert.Equal([]string{"ABC", "RTL"}, book.Groups("Triton"))
+}
+
+func TestShortSignals(t *testing.T) {
+	assert := assert.New(t)
+
+	var book ShortSignalBook
+	assert.NoError(book.AddSignal("KRTA", "Enemy convoy in sight"))
+	assert.Error(book.AddSignal("KRTA", "Am being hunted"), "KRTA is already taken")
+	assert.Error(book.AddSignal("KRT", "Am being hunted"), "Short signals are 4 letters")
+	meaning, err := book.Meaning("KRTA")
+	assert.NoError(err)
+	assert.Equal("Enemy convoy in sight", meaning)
+	_, err = book.Signal("Am being hunted")
+	assert.Error(err)
+
+	report := WeatherReport{
+		Grid: "AJ9863", Hour: 6, Pressure: 1013, Temperature: -4,
+		WindDirection: 27, WindForce: 7, Visibility: 5, Cloud: 8,
+	}
+	short, err := EncodeWeatherReport(report)
+	assert.NoError(err)
+	assert.Equal("AJOIZEPZQERZWUPUTI", short, "Unexpected weather short signal")
+
+	// Round trip through the Enigma.
+	enigma := MakeExampleEnigma(t)
+	encrypted := Type(enigma, short)
+	enigma.Reset()
+	decoded, err := DecodeWeatherReport(Type(enigma, encrypted))
+	assert.NoError(err)
+	assert.Equal(report, decoded, "The weather report did not survive the round trip")
+
+	report.WindForce = 13
+	_, err = EncodeWeatherReport(report)
+	assert.Error(err, "Wind force 13 is off the Beaufort scale")
+	_, err = DecodeWeatherReport("AJOIZEPZQERZWAPUTI")
+	assert.Error(err, "A does not stand for a digit")
+}
+
+func TestCrib(t *testing.T) {
+	assert := assert.New(t)
+
+	ciphertext := "QWETTERAXBCD"
+	assert.Equal([]int{0, 1, 2, 3, 4, 5}, CribClashes(ciphertext, "WETTER", 1))
+	assert.Equal([]int{3}, CribClashes(ciphertext, "WETTER", 0))
+	assert.Empty(CribClashes(ciphertext, "WETTER", 3))
+	assert.Equal([]int{3, 5, 6}, CribPositions(ciphertext, "WETTER"))
+
+	menu, err := CribMenu(ciphertext, "WETTER", 3)
+	assert.NoError(err)
+	assert.Equal(MenuLink{Position: 5, Plain: 'T', Cipher: 'E'}, menu[2])
+	assert.Equal([]byte("TERAWX"), MenuLetters(menu))
+	_, err = CribMenu(ciphertext, "WETTER", 1)
+	assert.Error(err, "The crib clashes at offset 1")
+	_, err = CribMenu(ciphertext, "WETTER", 7)
+	assert.Error(err, "The crib doesn't fit at offset 7")
+
+	var dot bytes.Buffer
+	assert.NoError(WriteMenuDOT(&dot, menu[:2]))
+	assert.Equal("graph menu {\n\tnode [shape=circle];\n\tT;\n\tE;\n\tW;\n"+
+		"\tW -- T [label=\"3\"];\n\tE -- T [label=\"4\"];\n}\n", dot.String())
+}
+
+func TestWeatherCrib(t *testing.T) {
+	assert := assert.New(t)
+
+	when := time.Date(1941, time.February, 3, 6, 0, 0, 0, time.UTC)
+	crib, err := WeatherCrib(when, "AJ9863")
+	assert.NoError(err)
+	assert.Equal("AJOIZEPZ", crib, "Unexpected crib")
+	_, err = WeatherCrib(when, "AJ98")
+	assert.Error(err, "Grid squares have 4 digits")
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		report, err := GenerateWeatherReport(rnd, when, "AJ9863")
+		assert.NoError(err)
+		short, err := EncodeWeatherReport(report)
+		assert.NoError(err, "Generated reports should be valid")
+		assert.True(strings.HasPrefix(short, crib), "Report %v doesn't start with the crib", short)
+	}
+}
+
+func TestGridSquare(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, grid := range []string{"AA1111", "AJ9863", "BF5555", "KZ9999", "UA7777"} {
+		lat, lon, err := GridSquareCenter(grid)
+		assert.NoError(err, grid)
+		square, err := GridSquare(lat, lon)
+		assert.NoError(err, grid)
+		assert.Equal(grid, square, "Grid square %v did not survive the round trip", grid)
+	}
+
+	// The north-west corner, and just inside the opposite corner of the first
+	// large square.
+	square, err := GridSquare(81, -100)
+	assert.NoError(err)
+	assert.Equal("AA1111", square)
+	square, err = GridSquare(81-gridSquareHeight+0.001, -100+gridSquareWidth-0.001)
+	assert.NoError(err)
+	assert.Equal("AA9999", square)
+
+	_, err = GridSquare(85, -30)
+	assert.Error(err, "85°N is north of the grid")
+	_, err = GridSquare(50, -120)
+	assert.Error(err, "120°W is west of the grid")
+	_, _, err = GridSquareCenter("ZA1111")
+	assert.Error(err, "Row Z is south of the South Pole")
+	_, _, err = GridSquareCenter("AJ9803")
+	assert.Error(err, "Grid square digits are 1-9")
+}
+
+func TestNetwork(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(1))
+
+	key, err := GenerateKeySheet(rnd, "M4", 10)
+	assert.NoError(err)
+	assert.NoError(Models["M4"].Validate(key.Reflector, key.Rotors))
+	assert.Equal(10, len(key.PlugPairs))
+	key, err = GenerateKeySheet(rnd, "I", 10)
+	assert.NoError(err)
+
+	start := time.Date(1941, time.May, 1, 8, 0, 0, 0, time.UTC)
+	network := Network{
+		Model: "I", Key: key, Stations: []string{"KOELN", "BERLIN", "WIEN"},
+		Interval: 10 * time.Minute, MaxDelay: time.Hour,
+	}
+	archive, err := network.Simulate(rnd, start, 20)
+	assert.NoError(err)
+	assert.Equal(20, len(archive))
+	for i, m := range archive {
+		assert.Equal(m.Plaintext, m.Decrypted, "Without noise, every message should decrypt")
+		assert.NotEqual(m.From, m.To)
+		assert.False(m.Received.Before(m.Sent))
+		if i > 0 {
+			assert.False(m.Received.Before(archive[i-1].Received), "The archive is out of order")
+		}
+	}
+
+	network.Noise = 0.1
+	archive, err = network.Simulate(rnd, start, 20)
+	assert.NoError(err)
+	garbled := 0
+	for _, m := range archive {
+		if m.Decrypted != m.Plaintext {
+			garbled++
+		}
+	}
+	assert.True(garbled > 0, "Noise should garble some messages")
+
+	network.Stations = []string{"KOELN"}
+	_, err = network.Simulate(rnd, start, 1)
+	assert.Error(err, "A network needs two stations")
+}
+
+func TestScenario(t *testing.T) {
+	assert := assert.New(t)
+
+	scenario, err := ReadScenario(strings.NewReader(`{
+		"start": "1941-05-01T06:00:00Z",
+		"messages": 6,
+		"stations": ["KOELN", "BERLIN"],
+		"schedule": ["08:00", "18:30"],
+		"texts": ["ALLES RUHIG"],
+		"seed": 7
+	}`))
+	assert.NoError(err)
+	assert.Equal("I", scenario.Model)
+	assert.Equal(10, scenario.PlugPairs)
+	archive, err := scenario.Run()
+	assert.NoError(err)
+	assert.Equal(6, len(archive))
+	assert.Equal(10, len(scenario.Key.PlugPairs))
+	assert.Equal(time.Date(1941, time.May, 1, 8, 0, 0, 0, time.UTC), archive[0].Sent)
+	assert.Equal(time.Date(1941, time.May, 3, 18, 30, 0, 0, time.UTC), archive[5].Sent)
+	for _, m := range archive {
+		assert.True(strings.HasSuffix(m.Plaintext, "XALLESXRUHIG"), m.Plaintext)
+		assert.Equal(m.Plaintext, m.Decrypted)
+	}
+
+	// The same scenario gives the same archive.
+	again, err := scenario.Run()
+	assert.NoError(err)
+	assert.Equal(archive, again)
+
+	var out strings.Builder
+	assert.NoError(WriteArchive(&out, archive[:1]))
+	lines := strings.Split(out.String(), "\n")
+	assert.True(strings.HasPrefix(lines[0], "1941-05-01 08:00 "), lines[0])
+	assert.NotContains(out.String(), archive[0].Plaintext)
+	out.Reset()
+	assert.NoError(WriteGroundTruth(&out, scenario.Key, archive))
+	assert.Contains(out.String(), archive[0].Plaintext)
+
+	_, err = ReadScenario(strings.NewReader(`{"messages": 1, "stations": ["A", "B"], "schedule": ["8am"]}`))
+	assert.Error(err, "Schedule times are like 08:00")
+	_, err = ReadScenario(strings.NewReader(`{"stations": ["A", "B"]}`))
+	assert.Error(err, "Scenarios need messages")
+}
+
+func TestArchiveJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	scenario := Scenario{
+		Network:   Network{Model: "I", Stations: []string{"KOELN", "WIEN"}},
+		Start:     date(1941, time.May, 1),
+		Messages:  3,
+		PlugPairs: 10,
+	}
+	archive, err := scenario.Run()
+	assert.NoError(err)
+	var records []ArchiveRecord
+	for _, t := range archive {
+		records = append(records, ArchiveRecord{Transmission: t, Model: "I", KeySheet: "1941-05-01"})
+	}
+	records[1].Ciphertext = "Q" + records[1].Ciphertext[1:]
+	records[2].MessageKey = ""
+
+	var out strings.Builder
+	assert.NoError(WriteArchiveJSON(&out, records))
+	again, err := ReadArchiveJSON(strings.NewReader(out.String()))
+	assert.NoError(err)
+	assert.Equal(records, again)
+
+	out.Reset()
+	assert.NoError(WriteGroundTruth(&out, scenario.Key, archive))
+	key, err := ReadKeySheet(strings.NewReader(out.String()), "")
+	assert.NoError(err)
+	assert.Equal(scenario.Key, key, "The ground truth should hold the key sheet")
+
+	expected, err := again[0].Reencrypt(key)
+	assert.NoError(err)
+	assert.Equal(again[0].Ciphertext, expected)
+	expected, err = again[1].Reencrypt(key)
+	assert.NoError(err)
+	assert.NotEqual(again[1].Ciphertext, expected, "The changed ciphertext should not verify")
+	assert.False(again[2].Verifiable(), "Messages without a key can't be verified")
+
+	_, err = ReadArchiveJSON(strings.NewReader("{\"model\": \"I\"}\nnot json\n"))
+	assert.Error(err)
+}
+
+func TestSealedKeySheets(t *testing.T) {
+	assert := assert.New(t)
+
+	var sealed strings.Builder
+	assert.NoError(Seal(&sealed, []byte("ANXWIEN"), "kennwort"))
+	assert.True(IsSealed([]byte(sealed.String())))
+	assert.NotContains(sealed.String(), "ANXWIEN")
+	data, err := Unseal([]byte(sealed.String()), "kennwort")
+	assert.NoError(err)
+	assert.Equal("ANXWIEN", string(data))
+	_, err = Unseal([]byte(sealed.String()), "passwort")
+	assert.True(errors.Is(err, ErrWrongPassphrase), err)
+
+	dir := t.TempDir()
+	key := KeySheet{Reflector: "B", Rotors: []string{"I", "II", "III"}, RingSettings: []byte("AAA")}
+	var store KeySheetStore = KeySheetDir{Dir: dir, Passphrase: "kennwort"}
+	assert.NoError(store.SaveKeySheet("1941-05-01", key))
+	loaded, err := store.LoadKeySheet("1941-05-01")
+	assert.NoError(err)
+	assert.Equal(key, loaded)
+	_, err = KeySheetDir{Dir: dir}.LoadKeySheet("1941-05-01")
+	assert.True(errors.Is(err, ErrWrongPassphrase), "Sealed key sheets need the passphrase: %v", err)
+	assert.Error(store.SaveKeySheet("../1941-05-01", key), "Names can't leave the directory")
+}
+
+func TestReadDecrypts(t *testing.T) {
+	assert := assert.New(t)
+
+	archive, problems, err := ReadDecrypts(strings.NewReader(`
+ZTPG/123456
+FROM: BDU
+TO: U 505
+TOO: 1420/14/5/43
+TOI: 1432
+KEY: QRS
+SYSTEM: TRITON
+
+VON BDU AN
+U 505 STANDORT MELDEN
+
+ZTPG/123457
+TOO: 2350/31/12/42
+TOI: 0010/1/1/43
+CIPHER: ABCDE FGH
+
+KEINE BESONDEREN VORKOMMNISSE
+`))
+	assert.NoError(err)
+	assert.Equal(2, len(archive))
+	first := archive[0]
+	assert.Equal("ZTPG/123456", first.Reference)
+	assert.Equal("BDU", first.From)
+	assert.Equal("U 505", first.To)
+	assert.Equal(time.Date(1943, time.May, 14, 14, 20, 0, 0, time.UTC), first.Sent)
+	assert.Equal(time.Date(1943, time.May, 14, 14, 32, 0, 0, time.UTC), first.Received)
+	assert.Equal("QRS", first.MessageKey)
+	assert.Equal("VONBDUANUSTANDORTMELDEN", first.Decrypted)
+	assert.Equal("ABCDEFGH", archive[1].Ciphertext)
+	assert.Equal(1943, archive[1].Received.Year())
+	assert.Equal([]TranscriptionProblem{
+		{11, 3, "ZTPG/123456: dropped illegal character '5'"},
+		{11, 4, "ZTPG/123456: dropped illegal character '0'"},
+		{11, 5, "ZTPG/123456: dropped illegal character '5'"},
+	}, problems)
+
+	_, _, err = ReadDecrypts(strings.NewReader("ZTPG/1\nTOO: 2500/1/1/43\n\nTEXT\n"))
+	assert.Error(err, "Invalid times should be rejected")
+	_, _, err = ReadDecrypts(strings.NewReader("ZTPG/1\nTOI: 1200\n\nTEXT\n"))
+	assert.Error(err, "A time without a date needs a TOO")
+	_, _, err = ReadDecrypts(strings.NewReader("VON BDU\n"))
+	assert.Error(err, "Records start with a serial")
+	_, _, err = ReadDecrypts(strings.NewReader("ZTPG/1\nFROM: BDU\n"))
+	assert.Error(err, "Records need text")
+}
+
+func TestBigramTableFile(t *testing.T) {
+	assert := assert.New(t)
+
+	table, err := ReadBigramTable(strings.NewReader("# Practice\nXK=PA RY=QC  # two pairs\n\nTZ=WN\n"))
+	assert.NoError(err)
+	sub, err := table.Substitute("QC")
+	assert.NoError(err)
+	assert.Equal("RY", sub)
+	assert.Error(ValidateBigramTable(table), "The table is incomplete")
+
+	_, err = ReadBigramTable(strings.NewReader("XK=PA\nRY=XK\n"))
+	assert.EqualError(err, `line 2: Bigram "XK" can't be mapped to "RY", it was previously mapped to "PA"`)
+	_, err = ReadBigramTable(strings.NewReader("XKPA\n"))
+	assert.Error(err, "Pairs need an '='")
+
+	generated := GenerateBigramTable(rand.New(rand.NewSource(1)))
+	assert.NoError(ValidateBigramTable(generated))
+	var file strings.Builder
+	assert.NoError(WriteBigramTable(&file, generated))
+	read, err := ReadBigramTable(strings.NewReader(file.String()))
+	assert.NoError(err)
+	assert.Equal(generated, read, "The table did not survive writing and reading")
+}
+
+func TestCleanTranscription(t *testing.T) {
+	assert := assert.New(t)
+
+	text := "1  gcdse AHUGW\n2. TQGRK  VL7FGX\n\n3: UCALX VYMIG"
+	cleaned, problems := CleanTranscription(text, 30)
+	assert.Equal("GCDSE AHUGW TQGRK VLFGX UCALX VYMIG", cleaned, "Unexpected cleanup")
+	assert.Equal([]TranscriptionProblem{
+		{Line: 2, Column: 13, Description: "dropped illegal character '7'"},
+	}, problems, "Unexpected problems")
+
+	// Numbers are only line numbers at the start of a line.
+	_, problems = CleanTranscription("GCDSE 12 AHUGW", 0)
+	assert.Len(problems, 2, "Numbers inside a line should be reported")
+
+	// A letter count that doesn't match the message is reported.
+	_, problems = CleanTranscription("GCDSE AHUGW", 13)
+	assert.Equal([]TranscriptionProblem{
+		{Description: "message has 10 letters, but its header states 13"},
+	}, problems, "Unexpected problems")
+}
+
+func TestLetterCount(t *testing.T) {
+	assert := assert.New(t)
+
+	// A letter dropped from the second group, and an extra letter in the third.
+	_, problems := CleanTranscription("GCDSE AHGW TQGRKK\nVLFGX UCA", 23)
+	assert.Empty(problems, "Offsetting errors can't be detected by the letter count")
+
+	_, problems = CleanTranscription("GCDSE AHGW TQGRK\nVLFGX UCA", 23)
+	assert.Equal([]TranscriptionProblem{
+		{Description: "message has 22 letters, but its header states 23"},
+		{Line: 1, Column: 7, Description: "group 2 (AHGW) has 4 letters instead of 5; 1 letter may be missing here"},
+	}, problems, "Unexpected problems")
+
+	_, problems = CleanTranscription("GCDSE AHUGW TQGRKK\nVLFGX UCA", 23)
+	assert.Equal([]TranscriptionProblem{
+		{Description: "message has 24 letters, but its header states 23"},
+		{Line: 1, Column: 13, Description: "group 3 (TQGRKK) has 6 letters instead of 5; 1 extra letter may be here"},
+	}, problems, "Unexpected problems")
+
+	// A whole group is missing.
+	_, problems = CleanTranscription("GCDSE AHUGW VLFGX UCA", 23)
+	assert.Equal([]TranscriptionProblem{
+		{Description: "message has 18 letters, but its header states 23"},
+		{Description: "1 whole group may be missing"},
+	}, problems, "Unexpected problems")
+}
+
+func TestMessageLength(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(250, Models["I"].MaxMessageLength)
+	assert.Equal(320, Models["M4"].MaxMessageLength)
+	assert.Equal(0, Models["G"].MaxMessageLength, "The G had no limit")
+
+	assert.NoError(CheckMessageLength("GCDSE AHUGW", 10), "Spaces don't count")
+	assert.ErrorIs(CheckMessageLength("GCDSE AHUGW T", 10), ErrMessageTooLong)
+	assert.NoError(CheckMessageLength("GCDSE AHUGW T", 0), "0 means no limit")
+
+	assert.Equal([]string{"GCDSE AHUGW"}, SplitMessage(" GCDSE  AHUGW ", 10))
+	assert.Equal([]string{"GCDSE AHUGW", "TQGRK VLFGX", "UCA"},
+		SplitMessage("GCDSE AHUGW TQGRK VLFGX UCA", 12), "Parts should end between groups")
+	assert.Equal([]string{"GCDSEAH", "UGWTQGR", "KVL"}, SplitMessage("GCDSEAHUGWTQGRKVL", 7),
+		"A group too long for a part should be cut")
+	assert.Equal([]string{"GC", "DSEAH", "UGWTQ", "GR"}, SplitMessage("GC DSEAHUGWTQ GR", 5))
+
+	// The simulated network sends long messages in parts.
+	rnd := rand.New(rand.NewSource(1))
+	key, err := GenerateKeySheet(rnd, "I", 10)
+	assert.NoError(err)
+	network := Network{
+		Model: "I", Key: key, Stations: []string{"KOELN", "BERLIN"},
+		Texts: []string{strings.Repeat("NACHSCHUB ", 30)},
+	}
+	archive, err := network.Simulate(rnd, time.Date(1941, time.May, 1, 8, 0, 0, 0, time.UTC), 1)
+	assert.NoError(err)
+	assert.Equal(2, len(archive))
+	assert.Equal(250, len(archive[0].Plaintext))
+	assert.NotEqual(archive[0].MessageKey+archive[0].Indicator, archive[1].MessageKey+archive[1].Indicator,
+		"Each part should have a key of its own")
+	for _, m := range archive {
+		assert.Equal(m.Plaintext, m.Decrypted)
+	}
+}
+
+func TestCheckHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	// The settings from the 1930 manual were fine in 1930...
+	manual := time.Date(1930, time.July, 7, 0, 0, 0, 0, time.UTC)
+	assert.Empty(CheckHistory(manual, "A", []string{"II", "I", "III"}, 6), "Unexpected anachronisms")
+
+	// ... but not in 1940.
+	war := time.Date(1940, time.May, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal([]string{
+		"reflector A was only in use until 1 November 1937",
+		"code books called for 10 plug pairs from 19 August 1939, not 6",
+	}, CheckHistory(war, "A", []string{"II", "I", "III"}, 6), "Unexpected anachronisms")
+
+	// Rotors IV and V came in December 1938.
+	assert.Equal([]string{
+		"rotor V was only in use from 15 December 1938",
+		"code books called for 5 to 8 plug pairs from 1 October 1936 until 1 January 1939, not 10",
+	}, CheckHistory(manual.AddDate(8, 0, 0), "B", []string{"II", "V", "III"}, 10), "Unexpected anachronisms")
+}
+
+func TestNewForDate(t *testing.T) {
+	assert := assert.New(t)
+
+	_, inventory, err := NewForDate("Heer", time.Date(1938, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Len(inventory.Rotors, 3, "Only rotors I-III existed in early 1938")
+	assert.Contains(inventory.Reflectors, "B")
+	assert.NotContains(inventory.Reflectors, "A")
+	assert.Equal(5, inventory.MinPlugPairs)
+	assert.Equal(8, inventory.MaxPlugPairs)
+
+	_, inventory, err = NewForDate("Heer", time.Date(1942, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.Len(inventory.Rotors, 5, "All five rotors were available in 1942")
+
+	_, _, err = NewForDate("Kriegsmarine", time.Date(1942, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Error(err, "There is no history for the navy")
+
+	// The machine is limited to its inventory.
+	e, _, err := NewForDate("Heer", time.Date(1939, time.October, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(err)
+	assert.ErrorIs(e.InstallRotors([]Rotor{Rotors["I"], Rotors["VI"], Rotors["III"]}), ErrInvalidRotor,
+		"Rotor VI was never issued to the Army")
+	assert.NoError(e.InstallRotors([]Rotor{Rotors["I"], Rotors["V"], Rotors["III"]}))
+	assert.ErrorIs(e.ReplaceRotor(1, Rotors["VI"]), ErrInvalidRotor)
+	e.InstallReflector(Reflectors["A"])
+	_, err = e.TryKeyPress('A')
+	assert.ErrorIs(err, ErrInvalidReflector, "Reflector A was withdrawn in 1937")
+	e.InstallReflector(Reflectors["B"])
+	_, err = e.TryKeyPress('A')
+	assert.ErrorIs(err, ErrWrongPlugCount, "The code books called for 10 plug pairs")
+	plugboard, err := parsePlugPairs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "MN", "OP", "QR", "ST"})
+	assert.NoError(err)
+	e.SetPlugboard(plugboard)
+	_, err = e.TryKeyPress('A')
+	assert.NoError(err)
+
+	// Its state can't be loaded with components it doesn't have either.
+	state, err := e.SaveState()
+	assert.NoError(err)
+	state.Rotors[0] = Component{Name: "VII"}
+	assert.ErrorIs(e.LoadState(state), ErrInvalidRotor)
+}
+
 // TODO: test "Operation Barbarossa, 1941" from http://wiki.franklinheath.co.uk/index.php/Enigma/Sample_Messages
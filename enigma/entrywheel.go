@@ -0,0 +1,89 @@
+package enigma
+
+import (
+	"sort"
+)
+
+// EntryWheels is the set of known entry wheels. "ABC" is the straight entry
+// wheel of the military Enigmas, "QWERTZU" that of the commercial and Abwehr
+// Enigmas, and "T" that of the Enigma T.
+var EntryWheels = map[string]EntryWheel{
+	"ABC":     mustMakeEntryWheel(letters),
+	"QWERTZU": qwertzuEntryWheel,
+	"T":       tirpitzEntryWheel,
+}
+
+// qwertzuEntryWheel is the entry wheel of the commercial and Abwehr Enigmas,
+// wired in the order of the keys on the keyboard.
+var qwertzuEntryWheel = mustMakeEntryWheel("QWERTZUIOASDFGHJKPYXCVBNML")
+
+// tirpitzEntryWheel is the entry wheel of the Enigma T, which is wired in
+// neither alphabetical nor keyboard order.
+var tirpitzEntryWheel = mustMakeEntryWheel("KZROUQHYAIGBLWVSTDXFPNMCJE")
+
+// EntryWheelNames returns the names of the known entry wheels, as a sorted slice of strings.
+func EntryWheelNames() []string {
+	names := make([]string, 0, len(EntryWheels))
+	for k := range EntryWheels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EntryWheel represents the entry stator (Eintrittswalze, ETW), which connects
+// the keyboard to the rightmost rotor's contacts. The military Enigmas wire it
+// straight ('A' to contact 0, 'B' to contact 1, and so forth), and that is what
+// a machine without an installed entry wheel does. Other models wire it in the
+// order of the keyboard, or in an order of their own.
+type EntryWheel struct {
+	// The key (by its number in the alphabet) connected to each contact, and
+	// its inverse.
+	key     [numLetters]byte
+	contact [numLetters]byte
+}
+
+// MakeEntryWheel turns a compact string representation of an entry wheel's
+// wiring into an EntryWheel. Position 0 holds the letter connected to contact
+// 0, and so forth; "ABCDEFGHIJKLMNOPQRSTUVWXYZ" is a straight entry wheel.
+func MakeEntryWheel(s string) (*EntryWheel, error) {
+	// An entry wheel is wired like a rotor, just in the other direction.
+	r, err := MakeRotor(s)
+	if err != nil {
+		return nil, err
+	}
+	var w EntryWheel
+	for i := uint8(0); i < r.contacts(); i++ {
+		w.key[i] = r.rlMapping[i]
+		w.contact[r.rlMapping[i]] = i
+	}
+	return &w, nil
+}
+
+// mustMakeEntryWheel does the same as MakeEntryWheel, but panics on errors,
+// like mustMakeRotor.
+func mustMakeEntryWheel(s string) EntryWheel {
+	w, err := MakeEntryWheel(s)
+	if err != nil {
+		panic(err)
+	}
+	return *w
+}
+
+// toContact returns the contact that the key with number `key` in the
+// alphabet connects to. A nil entry wheel is straight.
+func (w *EntryWheel) toContact(key uint8) uint8 {
+	if w == nil {
+		return key
+	}
+	return w.contact[key]
+}
+
+// toKey returns the number in the alphabet of the key (or lamp) that
+// `contact` connects to.
+func (w *EntryWheel) toKey(contact uint8) uint8 {
+	if w == nil {
+		return contact
+	}
+	return w.key[contact]
+}
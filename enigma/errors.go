@@ -0,0 +1,47 @@
+package enigma
+
+import "errors"
+
+// Errors that callers can tell apart with errors.Is. The errors this package
+// returns wrap one of these where it applies, along with the details.
+var (
+	// ErrInvalidRotor means that a rotor's wiring or turnover points don't
+	// make a working rotor, or that the rotor doesn't fit the machine.
+	ErrInvalidRotor = errors.New("invalid rotor")
+
+	// ErrInvalidReflector means that a reflector's wiring doesn't pair up its
+	// contacts, or that the reflector doesn't fit the machine.
+	ErrInvalidReflector = errors.New("invalid reflector")
+
+	// ErrInvalidEntryWheel means that an entry wheel doesn't fit the machine
+	// it was installed in.
+	ErrInvalidEntryWheel = errors.New("invalid entry wheel")
+
+	// ErrPlugConflict means that a letter was plugged into two plug pairs.
+	ErrPlugConflict = errors.New("plug conflict")
+
+	// ErrBadLetter means that a letter isn't one of the keys of the machine
+	// (or, for call signs and message texts, isn't a letter A-Z).
+	ErrBadLetter = errors.New("bad letter")
+
+	// ErrWrongRotorCount means that the number of rotors, or of settings
+	// for them, doesn't match the machine, or that a rotor slot doesn't
+	// exist.
+	ErrWrongRotorCount = errors.New("wrong number of rotors")
+
+	// ErrWrongPlugCount means that the plugboard has more or fewer plug
+	// pairs than the code books called for (see NewForDate).
+	ErrWrongPlugCount = errors.New("wrong number of plug pairs")
+
+	// ErrMessageTooLong means that a message is longer than procedure
+	// allowed (see Model.MaxMessageLength).
+	ErrMessageTooLong = errors.New("message too long")
+
+	// ErrMissingReflector means that a key was pressed before a reflector was
+	// installed.
+	ErrMissingReflector = errors.New("no reflector installed")
+
+	// ErrWrongPassphrase means that a sealed file couldn't be opened with the
+	// given passphrase (see Unseal). Damaged files look the same.
+	ErrWrongPassphrase = errors.New("wrong passphrase, or the file is damaged")
+)
@@ -0,0 +1,21 @@
+package enigma
+
+// NewG creates a new Enigma G, the Abwehr's gear-driven machine (this package
+// models the G-312). Unlike the Enigma I, it has:
+//   - Cog-wheel stepping (see GearStepping): each rotor turns its left neighbour
+//     like an odometer, with no double step. Its rotors have many notches each.
+//   - A reflector that can be set to a position (see SetReflectorPosition), and
+//     that is turned by the leftmost rotor, as if it were a fourth rotor.
+//   - An entry wheel wired in keyboard order.
+//   - No plugboard.
+//
+// Its rotors are "G-I" through "G-III" in Rotors, and its reflector is "G" in
+// Reflectors.
+func NewG() Enigma {
+	return &enigma{
+		alphabet:          letters,
+		settableReflector: true,
+		stepping:          GearStepping{},
+		entry:             &qwertzuEntryWheel,
+	}
+}
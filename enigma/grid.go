@@ -0,0 +1,95 @@
+package enigma
+
+import (
+	"fmt"
+	"math"
+)
+
+// The Kriegsmarine's grid chart (Marinequadratkarte) divided the oceans into
+// large squares named by two letters, such as "AJ", each 486 nautical miles
+// across. Each large square is divided into 3 by 3 smaller squares, numbered
+// 1 to 9 from the north-west corner, row by row:
+//
+//	1 2 3
+//	4 5 6
+//	7 8 9
+//
+// and each of those again, four times over, so that a square like "AJ9863"
+// is 6 nautical miles across.
+//
+// The real chart lettered its large squares to fit its map sheets, and sized
+// them in nautical miles, and neither is reproduced here. Instead, the large
+// squares are 486 nautical miles (8.1 degrees) high and 12 degrees of
+// longitude wide, which makes them roughly square in the North Atlantic. The
+// first letter counts rows of them southwards from 81°N, and the second
+// counts columns eastwards from 100°W.
+
+// The size of a large square, in degrees.
+const gridSquareHeight, gridSquareWidth = 486.0 / 60, 12.0
+
+// The north-west corner of the grid.
+const gridNorth, gridWest = 81.0, -100.0
+
+// gridDigits is the number of digits in a grid square.
+const gridDigits = 4
+
+// GridSquare returns the grid square, such as "AJ9863", that contains the
+// position at latitude `lat` and longitude `lon`, in degrees (north and east
+// are positive). It returns an error for positions outside the grid.
+func GridSquare(lat, lon float64) (string, error) {
+	if lat > gridNorth || lat < -90 {
+		return "", fmt.Errorf("latitude %v is outside the grid, which covers %v to -90", lat, gridNorth)
+	}
+	if lon < -180 || lon > 180 {
+		return "", fmt.Errorf("longitude %v is not between -180 and 180", lon)
+	}
+	fy := (gridNorth - lat) / gridSquareHeight
+	row := int(math.Floor(fy))
+	// The grid wraps around past 180°.
+	fx := math.Mod(lon-gridWest+360, 360) / gridSquareWidth
+	column := int(math.Floor(fx))
+	if column >= int(numLetters) {
+		return "", fmt.Errorf(
+			"longitude %v is outside the grid, which covers %v eastwards for %v degrees",
+			lon, gridWest, float64(numLetters)*gridSquareWidth)
+	}
+	square := []byte{'A' + byte(row), 'A' + byte(column)}
+	fy, fx = fy-float64(row), fx-float64(column)
+	for i := 0; i < gridDigits; i++ {
+		y, x := math.Min(math.Floor(fy*3), 2), math.Min(math.Floor(fx*3), 2)
+		square = append(square, '1'+byte(y*3+x))
+		fy, fx = fy*3-y, fx*3-x
+	}
+	return string(square), nil
+}
+
+// GridSquareCenter returns the latitude and longitude, in degrees, of the
+// middle of grid square `grid`, such as "AJ9863".
+func GridSquareCenter(grid string) (lat, lon float64, err error) {
+	if err := checkGridSquare(grid); err != nil {
+		return 0, 0, err
+	}
+	row, column := int(grid[0]-'A'), int(grid[1]-'A')
+	// The position within the large square, as fractions of its size.
+	var fy, fx float64
+	size := 1.0
+	for i := 2; i < len(grid); i++ {
+		if grid[i] == '0' {
+			return 0, 0, fmt.Errorf("grid square %q has a 0; its digits are 1-9", grid)
+		}
+		d := int(grid[i] - '1')
+		size /= 3
+		fy += float64(d/3) * size
+		fx += float64(d%3) * size
+	}
+	fy, fx = fy+size/2, fx+size/2
+	lat = gridNorth - (float64(row)+fy)*gridSquareHeight
+	if lat < -90 {
+		return 0, 0, fmt.Errorf("grid square %q is south of the South Pole", grid)
+	}
+	lon = gridWest + (float64(column)+fx)*gridSquareWidth
+	if lon > 180 {
+		lon -= 360
+	}
+	return lat, lon, nil
+}
@@ -0,0 +1,183 @@
+package enigma
+
+import (
+	"fmt"
+	"time"
+)
+
+// Services whose history this package knows. Only the Army's Enigma I is
+// covered so far.
+var services = []string{"Heer"}
+
+// period is a span of time during which a component or procedure was in use
+// by the German Army. A zero `from` or `until` means the span is open-ended.
+type period struct {
+	from, until time.Time
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (p period) contains(t time.Time) bool {
+	return (p.from.IsZero() || !t.Before(p.from)) && (p.until.IsZero() || t.Before(p.until))
+}
+
+func (p period) String() string {
+	switch {
+	case p.from.IsZero():
+		return fmt.Sprintf("until %v", p.until.Format("2 January 2006"))
+	case p.until.IsZero():
+		return fmt.Sprintf("from %v", p.from.Format("2 January 2006"))
+	}
+	return fmt.Sprintf(
+		"from %v until %v", p.from.Format("2 January 2006"), p.until.Format("2 January 2006"))
+}
+
+// rotorHistory records when each of the Rotors was in use.
+var rotorHistory = map[string]period{
+	"I":   {},
+	"II":  {},
+	"III": {},
+	"IV":  {from: date(1938, time.December, 15)},
+	"V":   {from: date(1938, time.December, 15)},
+}
+
+// reflectorHistory records when each of the Reflectors was in use. Reflector C
+// saw only brief use, and the date it was introduced is approximate.
+var reflectorHistory = map[string]period{
+	"A": {until: date(1937, time.November, 1)},
+	"B": {from: date(1937, time.November, 1)},
+	"C": {from: date(1940, time.January, 1)},
+}
+
+// plugPairPolicy is the number of plug pairs the code books called for during
+// a period.
+type plugPairPolicy struct {
+	period
+	min, max int
+}
+
+var plugPairHistory = []plugPairPolicy{
+	{period{until: date(1936, time.October, 1)}, 6, 6},
+	{period{date(1936, time.October, 1), date(1939, time.January, 1)}, 5, 8},
+	{period{date(1939, time.January, 1), date(1939, time.August, 19)}, 7, 10},
+	{period{from: date(1939, time.August, 19)}, 10, 10},
+}
+
+// CheckHistory checks machine settings against what the German Army's code
+// books could have called for on the given date, and returns a description of
+// every anachronism it finds. Components are given by their name in Rotors
+// and Reflectors. Components this package has no history for are not
+// reported.
+func CheckHistory(t time.Time, reflector string, rotors []string, plugPairs int) []string {
+	var anachronisms []string
+	if p, ok := reflectorHistory[reflector]; ok && !p.contains(t) {
+		anachronisms = append(anachronisms, fmt.Sprintf(
+			"reflector %v was only in use %v", reflector, p))
+	}
+	for _, rotor := range rotors {
+		if p, ok := rotorHistory[rotor]; ok && !p.contains(t) {
+			anachronisms = append(anachronisms, fmt.Sprintf(
+				"rotor %v was only in use %v", rotor, p))
+		}
+	}
+	for _, policy := range plugPairHistory {
+		if !policy.contains(t) || (plugPairs >= policy.min && plugPairs <= policy.max) {
+			continue
+		}
+		count := fmt.Sprint(policy.min)
+		if policy.min != policy.max {
+			count = fmt.Sprintf("%v to %v", policy.min, policy.max)
+		}
+		anachronisms = append(anachronisms, fmt.Sprintf(
+			"code books called for %v plug pairs %v, not %v", count, policy.period, plugPairs))
+	}
+	return anachronisms
+}
+
+// Inventory lists the components and procedures a service had available at
+// some point in time.
+type Inventory struct {
+	Rotors     map[string]Rotor
+	Reflectors map[string]Reflector
+
+	// The range of plug pairs the code books called for.
+	MinPlugPairs, MaxPlugPairs int
+}
+
+// hasRotor returns whether `rotor` is one of the inventory's rotors. A nil
+// inventory has every rotor, as do the following two methods.
+func (inv *Inventory) hasRotor(rotor Rotor) bool {
+	if inv == nil {
+		return true
+	}
+	// Only WiredRotors can be compared.
+	wired, ok := rotor.(WiredRotor)
+	if !ok {
+		return false
+	}
+	for _, r := range inv.Rotors {
+		if r, ok := r.(WiredRotor); ok && r == wired {
+			return true
+		}
+	}
+	return false
+}
+
+// hasReflector returns whether `reflector` is one of the inventory's
+// reflectors.
+func (inv *Inventory) hasReflector(reflector Reflector) bool {
+	if inv == nil {
+		return true
+	}
+	for _, r := range inv.Reflectors {
+		if r == reflector {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPlugPairs returns whether the code books called for `n` plug pairs.
+func (inv *Inventory) hasPlugPairs(n int) bool {
+	return inv == nil || (n >= inv.MinPlugPairs && n <= inv.MaxPlugPairs)
+}
+
+// NewForDate creates an Enigma as it would have been issued to `service` (see
+// `services`) on the given date, along with the Inventory of components and
+// procedures available to its operators at the time. The Enigma is limited to
+// that inventory: InstallRotors and ReplaceRotor refuse other rotors, and
+// TryKeyPress refuses to type with another reflector, or with more or fewer
+// plug pairs than the code books called for.
+func NewForDate(service string, t time.Time) (Enigma, Inventory, error) {
+	inventory := Inventory{
+		Rotors:     make(map[string]Rotor),
+		Reflectors: make(map[string]Reflector),
+	}
+	known := false
+	for _, s := range services {
+		known = known || s == service
+	}
+	if !known {
+		return nil, inventory, fmt.Errorf(
+			"no history for service %q; options are %v", service, services)
+	}
+
+	for name, p := range rotorHistory {
+		if p.contains(t) {
+			inventory.Rotors[name] = Rotors[name]
+		}
+	}
+	for name, p := range reflectorHistory {
+		if p.contains(t) {
+			inventory.Reflectors[name] = Reflectors[name]
+		}
+	}
+	for _, policy := range plugPairHistory {
+		if policy.contains(t) {
+			inventory.MinPlugPairs, inventory.MaxPlugPairs = policy.min, policy.max
+		}
+	}
+	return &enigma{alphabet: letters, inventory: &inventory}, inventory, nil
+}
@@ -0,0 +1,112 @@
+package enigma
+
+import (
+	"html/template"
+	"io"
+)
+
+// traceHTML is the page WriteTraceHTML writes. It needs nothing but a
+// browser: the traces are embedded as JSON, and a small script shows one key
+// press at a time.
+var traceHTML = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+#message span { font-family: monospace; font-size: 1.4em; padding: 0 1px; cursor: pointer; }
+#message span.current { background: #fd0; }
+#controls { margin: 1em 0; }
+#controls input[type=range] { width: 30em; vertical-align: middle; }
+#path { border-collapse: collapse; font-family: monospace; }
+#path td, #path th { border: 1px solid #999; padding: 0.2em 0.6em; text-align: center; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Scrub through the key presses with the slider or the arrow keys, or click a letter.</p>
+<div id="message"></div>
+<div id="controls">
+<button id="prev">&larr;</button>
+<input id="scrub" type="range" min="0" value="0">
+<button id="next">&rarr;</button>
+<span id="counter"></span>
+</div>
+<p id="summary"></p>
+<table id="path"><thead><tr><th>#</th><th>Component</th><th>Signal</th></tr></thead>
+<tbody></tbody></table>
+<script>
+const traces = {{.Traces}};
+const scrub = document.getElementById("scrub");
+const message = document.getElementById("message");
+scrub.max = Math.max(traces.length - 1, 0);
+traces.forEach((t, i) => {
+  const span = document.createElement("span");
+  span.textContent = t.lamp;
+  span.title = t.key + " → " + t.lamp;
+  span.onclick = () => show(i);
+  message.appendChild(span);
+});
+function component(t, step) {
+  if (step.stage === "rotor") {
+    return "rotor " + (step.slot + 1) + " at " + t.positions[step.slot];
+  }
+  if (step.stage === "reflector" && t.reflectorPosition) {
+    return "reflector at " + t.reflectorPosition;
+  }
+  return step.stage;
+}
+function show(i) {
+  if (traces.length === 0) {
+    return;
+  }
+  i = Math.min(Math.max(i, 0), traces.length - 1);
+  scrub.value = i;
+  const t = traces[i];
+  document.getElementById("counter").textContent = "key " + (i + 1) + " of " + traces.length;
+  let summary = "Key " + t.key + " lights lamp " + t.lamp + ". Rotors at " + t.positions;
+  if (t.reflectorPosition) {
+    summary += ", reflector at " + t.reflectorPosition;
+  }
+  document.getElementById("summary").textContent = summary + ".";
+  const rows = document.querySelector("#path tbody");
+  rows.innerHTML = "";
+  const add = (n, name, signal) => {
+    const row = rows.insertRow();
+    [n, name, signal].forEach(text => { row.insertCell().textContent = text; });
+  };
+  add("", "key", t.key);
+  t.steps.forEach((step, n) => add(n + 1, component(t, step), step.signal));
+  add("", "lamp", t.lamp);
+  Array.from(message.children).forEach((span, j) => {
+    span.className = j === i ? "current" : "";
+  });
+}
+scrub.oninput = () => show(Number(scrub.value));
+document.getElementById("prev").onclick = () => show(Number(scrub.value) - 1);
+document.getElementById("next").onclick = () => show(Number(scrub.value) + 1);
+document.onkeydown = e => {
+  if (e.target === scrub) return; // The slider handles its own keys.
+  if (e.key === "ArrowLeft") show(Number(scrub.value) - 1);
+  if (e.key === "ArrowRight") show(Number(scrub.value) + 1);
+};
+show(0);
+</script>
+</body>
+</html>
+`))
+
+// WriteTraceHTML writes the traces of a message's key presses (see
+// TypeTraced), in order, as a standalone HTML page titled `title`. The page
+// works offline: it shows the message, and lets the reader scrub through the
+// key presses to see the rotor positions and the signal's path for each.
+func WriteTraceHTML(w io.Writer, title string, traces []Trace) error {
+	if traces == nil {
+		traces = []Trace{}
+	}
+	return traceHTML.Execute(w, struct {
+		Title  string
+		Traces []Trace
+	}{title, traces})
+}
@@ -0,0 +1,18 @@
+package enigma
+
+// NewK creates a new commercial Enigma K. It steps like the Enigma I, but has:
+//   - A reflector that can be set to a position (see SetReflectorPosition),
+//     which stays put during operation.
+//   - An entry wheel wired in keyboard order.
+//   - No plugboard.
+//
+// Its rotors are "K-I" through "K-III" in Rotors, and its reflector is "K" in
+// Reflectors. The Swiss Army's K machines are the same, except with rotors
+// "SK-I" through "SK-III".
+func NewK() Enigma {
+	return &enigma{
+		alphabet:          letters,
+		settableReflector: true,
+		entry:             &qwertzuEntryWheel,
+	}
+}
@@ -0,0 +1,129 @@
+package enigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Key sheet files hold a single key sheet in JSON, as WriteKeySheet and
+// WriteGroundTruth write it:
+//
+//	{"reflector": "B", "rotors": ["I", "IV", "III"], "ringSettings": "ACZ", "plugPairs": ["AB", "CD"]}
+//
+// They can be sealed with a passphrase (see Seal), to hand out the keys to an
+// exercise before it ends without giving them away.
+
+// keySheetJSON is the JSON form of a KeySheet.
+type keySheetJSON struct {
+	Reflector    string   `json:"reflector"`
+	Rotors       []string `json:"rotors"`
+	RingSettings string   `json:"ringSettings"`
+	PlugPairs    []string `json:"plugPairs"`
+}
+
+func toKeySheetJSON(key KeySheet) keySheetJSON {
+	return keySheetJSON{
+		Reflector:    key.Reflector,
+		Rotors:       key.Rotors,
+		RingSettings: string(key.RingSettings),
+		PlugPairs:    key.PlugPairs,
+	}
+}
+
+// WriteKeySheet writes `key` in the key sheet file format above.
+func WriteKeySheet(w io.Writer, key KeySheet) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toKeySheetJSON(key))
+}
+
+// ReadKeySheet reads a key sheet file in the format above. Other fields, such
+// as the messages in a ground truth file, are ignored. If the file is sealed,
+// `passphrase` opens it.
+func ReadKeySheet(r io.Reader, passphrase string) (KeySheet, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return KeySheet{}, err
+	}
+	if IsSealed(data) {
+		if data, err = Unseal(data, passphrase); err != nil {
+			return KeySheet{}, fmt.Errorf("could not open key sheet: %w", err)
+		}
+	}
+	var k keySheetJSON
+	if err := json.Unmarshal(data, &k); err != nil {
+		return KeySheet{}, fmt.Errorf("could not read key sheet: %v", err)
+	}
+	return KeySheet{
+		Reflector:    k.Reflector,
+		Rotors:       k.Rotors,
+		RingSettings: []byte(k.RingSettings),
+		PlugPairs:    k.PlugPairs,
+	}, nil
+}
+
+// A KeySheetStore keeps key sheets by name, such as the date they're for.
+type KeySheetStore interface {
+	LoadKeySheet(name string) (KeySheet, error)
+	SaveKeySheet(name string, key KeySheet) error
+}
+
+// KeySheetDir is a KeySheetStore that keeps each key sheet in a file called
+// <name>.json in the directory Dir. If it has a Passphrase, it seals the key
+// sheets it saves, and opens sealed ones it loads.
+type KeySheetDir struct {
+	Dir, Passphrase string
+}
+
+// path returns the file that holds the key sheet called `name`.
+func (d KeySheetDir) path(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", fmt.Errorf("key sheet names can't be empty or contain a path, got %q", name)
+	}
+	return filepath.Join(d.Dir, name+".json"), nil
+}
+
+// LoadKeySheet implements KeySheetStore.
+func (d KeySheetDir) LoadKeySheet(name string) (KeySheet, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return KeySheet{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return KeySheet{}, err
+	}
+	defer f.Close()
+	key, err := ReadKeySheet(f, d.Passphrase)
+	if err != nil {
+		return KeySheet{}, fmt.Errorf("%v: %w", path, err)
+	}
+	return key, nil
+}
+
+// SaveKeySheet implements KeySheetStore.
+func (d KeySheetDir) SaveKeySheet(name string, key KeySheet) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	if err := WriteKeySheet(&buffer, key); err != nil {
+		return err
+	}
+	data := buffer.Bytes()
+	if d.Passphrase != "" {
+		var sealed bytes.Buffer
+		if err := Seal(&sealed, data, d.Passphrase); err != nil {
+			return err
+		}
+		data = sealed.Bytes()
+	}
+	// Key sheets are secrets, so keep them from other users.
+	return ioutil.WriteFile(path, data, 0600)
+}
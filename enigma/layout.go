@@ -0,0 +1,81 @@
+package enigma
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Layout is the arrangement of an Enigma's keys on its keyboard, which its
+// lampboard repeats: the lamp for each letter sits in the same place as its
+// key.
+type Layout struct {
+	// The rows of keys, top to bottom, each listed left to right.
+	Rows []string
+}
+
+// Layouts is the set of known keyboard layouts. "QWERTZU" is that of the
+// lettered Enigmas; note that it has no fourth row, and that P and L sit at
+// either end of the bottom row. Use RegisterLayout to add others.
+var Layouts = map[string]Layout{
+	"QWERTZU": {Rows: []string{"QWERTZUIO", "ASDFGHJK", "PYXCVBNML"}},
+}
+
+// LayoutNames returns the names of the known layouts, as a sorted slice of strings.
+func LayoutNames() []string {
+	names := make([]string, 0, len(Layouts))
+	for k := range Layouts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Position returns the row and column (both 0-based) of `key` in the layout,
+// or false if the layout has no such key.
+func (l Layout) Position(key byte) (row, column int, ok bool) {
+	for r, keys := range l.Rows {
+		for c := 0; c < len(keys); c++ {
+			if keys[c] == key {
+				return r, c, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// ValidateLayout returns `nil` if the given Layout has every letter A-Z on
+// exactly one key, or an error otherwise.
+func ValidateLayout(l Layout) error {
+	var seen [numLetters]bool
+	for _, keys := range l.Rows {
+		for i := 0; i < len(keys); i++ {
+			key := keys[i]
+			if key < 'A' || key > 'Z' {
+				return fmt.Errorf("invalid layout %v: %q is not a letter", l.Rows, key)
+			}
+			if seen[key-'A'] {
+				return fmt.Errorf("invalid layout %v: %q appears more than once", l.Rows, key)
+			}
+			seen[key-'A'] = true
+		}
+	}
+	for i, present := range seen {
+		if !present {
+			return fmt.Errorf("invalid layout %v: letter %q is missing", l.Rows, byte(i+'A'))
+		}
+	}
+	return nil
+}
+
+// RegisterLayout adds a layout to Layouts under the given name, after checking
+// it with ValidateLayout. Existing layouts can't be replaced.
+func RegisterLayout(name string, l Layout) error {
+	if _, exists := Layouts[name]; exists {
+		return fmt.Errorf("could not register layout: %q already exists", name)
+	}
+	if err := ValidateLayout(l); err != nil {
+		return err
+	}
+	Layouts[name] = l
+	return nil
+}
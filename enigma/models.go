@@ -0,0 +1,191 @@
+package enigma
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Model describes an Enigma model: which components were issued for it, and
+// how it is built.
+type Model struct {
+	// The names of the rotors (in Rotors) and reflectors (in Reflectors) that
+	// fit this model.
+	Rotors, Reflectors []string
+
+	// The number of rotors the model takes.
+	RotorSlots int
+
+	// Whether the model has a plugboard.
+	Plugboard bool
+
+	// Whether the model's reflector can be set to a position, like a rotor
+	// (see Enigma.SetReflectorPosition).
+	SettableReflector bool
+
+	// The model's keys, which are also what its ring settings and rotor
+	// positions are expressed in: the letters A-Z, or for the Enigma Z the
+	// digits 1-9 and 0.
+	Alphabet Alphabet
+
+	// The most letters that procedure allowed in one message, not counting
+	// the indicator, or 0 if there was no limit. Longer messages were split
+	// into parts, each sent as a message of its own (see SplitMessage).
+	MaxMessageLength int
+
+	// A typical choice of components, e.g. for suggesting defaults.
+	DefaultReflector string
+	DefaultRotors    []string
+
+	// new creates a machine of this model, without components.
+	new func() Enigma
+}
+
+var enigmaIRotors = []string{"I", "II", "III", "IV", "V"}
+var navalRotors = []string{"I", "II", "III", "IV", "V", "VI", "VII", "VIII"}
+
+// Models is the set of supported Enigma models.
+var Models = map[string]Model{
+	"I": {
+		Rotors: enigmaIRotors, Reflectors: []string{"A", "B", "C"},
+		RotorSlots: 3, Plugboard: true, Alphabet: letters, MaxMessageLength: 250,
+		DefaultReflector: "B", DefaultRotors: []string{"I", "II", "III"},
+		new: New,
+	},
+	"M3": {
+		Rotors: navalRotors, Reflectors: []string{"B", "C"},
+		RotorSlots: 3, Plugboard: true, Alphabet: letters, MaxMessageLength: 320,
+		DefaultReflector: "B", DefaultRotors: []string{"I", "II", "III"},
+		new: New,
+	},
+	"M4": {
+		Rotors: append([]string{"Beta", "Gamma"}, navalRotors...), Reflectors: []string{"B-thin", "C-thin"},
+		RotorSlots: 4, Plugboard: true, Alphabet: letters, MaxMessageLength: 320,
+		DefaultReflector: "B-thin", DefaultRotors: []string{"Beta", "I", "II", "III"},
+		new: New,
+	},
+	"G": {
+		Rotors: []string{"G-I", "G-II", "G-III"}, Reflectors: []string{"G"},
+		RotorSlots: 3, SettableReflector: true, Alphabet: letters,
+		DefaultReflector: "G", DefaultRotors: []string{"G-I", "G-II", "G-III"},
+		new: NewG,
+	},
+	"K": {
+		Rotors: []string{"K-I", "K-II", "K-III"}, Reflectors: []string{"K"},
+		RotorSlots: 3, SettableReflector: true, Alphabet: letters,
+		DefaultReflector: "K", DefaultRotors: []string{"K-I", "K-II", "K-III"},
+		new: NewK,
+	},
+	// The Swiss Army's K, with rewired rotors.
+	"Swiss-K": {
+		Rotors: []string{"SK-I", "SK-II", "SK-III"}, Reflectors: []string{"K"},
+		RotorSlots: 3, SettableReflector: true, Alphabet: letters,
+		DefaultReflector: "K", DefaultRotors: []string{"SK-I", "SK-II", "SK-III"},
+		new: NewK,
+	},
+	"T": {
+		Rotors:     []string{"T-I", "T-II", "T-III", "T-IV", "T-V", "T-VI", "T-VII", "T-VIII"},
+		Reflectors: []string{"T"},
+		RotorSlots: 3, SettableReflector: true, Alphabet: letters,
+		DefaultReflector: "T", DefaultRotors: []string{"T-I", "T-II", "T-III"},
+		new: NewT,
+	},
+	// Postwar Norway's Enigma I machines, with rewired rotors and reflector.
+	"Norenigma": {
+		Rotors: []string{"N-I", "N-II", "N-III", "N-IV", "N-V"}, Reflectors: []string{"N"},
+		RotorSlots: 3, Plugboard: true, Alphabet: letters,
+		DefaultReflector: "N", DefaultRotors: []string{"N-I", "N-II", "N-III"},
+		new: New,
+	},
+	"Z": {
+		Rotors: []string{"Z-I", "Z-II", "Z-III"}, Reflectors: []string{"Z"},
+		RotorSlots: 3, SettableReflector: true, Alphabet: digits,
+		DefaultReflector: "Z", DefaultRotors: []string{"Z-I", "Z-II", "Z-III"},
+		new: NewZ,
+	},
+}
+
+// ModelNames returns the names of the supported models, as a sorted slice of strings.
+func ModelNames() []string {
+	names := make([]string, 0, len(Models))
+	for k := range Models {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fittingRotors returns the names of the rotors that fit this model: those
+// issued for it, and, if its keys are letters, the custom ones (see
+// RegisterRotor).
+func (m Model) fittingRotors() []string {
+	if m.Alphabet != letters {
+		return m.Rotors
+	}
+	return append(append([]string(nil), m.Rotors...), customRotors()...)
+}
+
+// fittingReflectors returns the names of the reflectors that fit this model,
+// like fittingRotors.
+func (m Model) fittingReflectors() []string {
+	if m.Alphabet != letters {
+		return m.Reflectors
+	}
+	return append(append([]string(nil), m.Reflectors...), customReflectors()...)
+}
+
+// Validate returns `nil` if the named reflector and rotors (listed
+// left-to-right) can be installed together in this model, or an error
+// otherwise. Besides checking that they were issued for the model (or are
+// custom components) and that the number of rotors is right, it checks that
+// they fit together on the spindle (see ValidateSpindle).
+func (m Model) Validate(reflector string, rotors []string) error {
+	if reflectors := m.fittingReflectors(); !contains(reflectors, reflector) {
+		return fmt.Errorf("reflector %v doesn't fit this model; options are %v", reflector, reflectors)
+	}
+	if len(rotors) != m.RotorSlots {
+		return fmt.Errorf("%w: this model takes %v rotors, but got rotors %v",
+			ErrWrongRotorCount, m.RotorSlots, rotors)
+	}
+	fitting := m.fittingRotors()
+	components := make([]Rotor, len(rotors))
+	for i, name := range rotors {
+		if !contains(fitting, name) {
+			return fmt.Errorf("rotor %v doesn't fit this model; options are %v", name, fitting)
+		}
+		components[i] = Rotors[name]
+	}
+	return ValidateSpindle(Reflectors[reflector], components)
+}
+
+// NewModel creates an Enigma of the named model (see Models), with the named
+// reflector and rotors (listed left-to-right) installed. It returns an error
+// if the model doesn't exist, or the components don't fit it (see
+// Model.Validate).
+func NewModel(name string, reflector string, rotors []string) (Enigma, error) {
+	m, ok := Models[name]
+	if !ok {
+		return nil, fmt.Errorf("model %v does not exist; options are %v", name, ModelNames())
+	}
+	if err := m.Validate(reflector, rotors); err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", name, err)
+	}
+	e := m.new()
+	e.InstallReflector(Reflectors[reflector])
+	components := make([]Rotor, len(rotors))
+	for i, r := range rotors {
+		components[i] = Rotors[r]
+	}
+	if err := e.InstallRotors(components); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,156 @@
+package enigma
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A BigramTable (Doppelbuchstabentauschtafel) is the Kriegsmarine's table for
+// superenciphering message indicators. Like a Plugboard it swaps its entries in
+// pairs, but its entries are bigrams: if "AB" maps to "XY", "XY" maps to "AB".
+type BigramTable struct {
+	mapping map[string]string
+}
+
+func isBigram(b string) bool {
+	return len(b) == 2 && b[0] >= 'A' && b[0] <= 'Z' && b[1] >= 'A' && b[1] <= 'Z'
+}
+
+// AddPair creates a mapping between the bigrams `left` and `right`.
+func (t *BigramTable) AddPair(left, right string) error {
+	if t.mapping == nil {
+		t.mapping = make(map[string]string)
+	}
+	if !isBigram(left) || !isBigram(right) {
+		return fmt.Errorf("Bigrams must be 2 letters, such as 'AB'. Got %q and %q", left, right)
+	}
+	if left == right {
+		return fmt.Errorf("Bigram %q can't be mapped to itself", left)
+	}
+	if prev, mapped := t.mapping[left]; mapped {
+		return fmt.Errorf(
+			"Bigram %q can't be mapped to %q, it was previously mapped to %q", left, right, prev)
+	}
+	if prev, mapped := t.mapping[right]; mapped {
+		return fmt.Errorf(
+			"Bigram %q can't be mapped to %q, it was previously mapped to %q", right, left, prev)
+	}
+	t.mapping[left] = right
+	t.mapping[right] = left
+	return nil
+}
+
+// Substitute returns the bigram that `bigram` maps to, or an error if the
+// table doesn't cover it.
+func (t *BigramTable) Substitute(bigram string) (string, error) {
+	sub, ok := t.mapping[bigram]
+	if !ok {
+		return "", fmt.Errorf("bigram %q is not in the table", bigram)
+	}
+	return sub, nil
+}
+
+// A Kenngruppenbuch is the book of identifying groups (Kenngruppen). Each
+// Kenngruppe is a trigram that identifies the key net (Schlüsselkreis) whose
+// settings a message was encrypted with.
+type Kenngruppenbuch struct {
+	nets map[string]string
+}
+
+// AddGroup records that the Kenngruppe `group` identifies the key net `net`.
+func (k *Kenngruppenbuch) AddGroup(group, net string) error {
+	if k.nets == nil {
+		k.nets = make(map[string]string)
+	}
+	if !isTrigram(group) {
+		return fmt.Errorf("Kenngruppen must be 3 letters, such as 'ABC'. Got %q", group)
+	}
+	if prev, ok := k.nets[group]; ok {
+		return fmt.Errorf("Kenngruppe %q already identifies key net %q", group, prev)
+	}
+	k.nets[group] = net
+	return nil
+}
+
+// Net returns the key net that the Kenngruppe `group` identifies.
+func (k *Kenngruppenbuch) Net(group string) (string, error) {
+	net, ok := k.nets[group]
+	if !ok {
+		return "", fmt.Errorf("Kenngruppe %q is not in the book", group)
+	}
+	return net, nil
+}
+
+// Groups returns the Kenngruppen that identify the key net `net`, sorted.
+func (k *Kenngruppenbuch) Groups(net string) []string {
+	var groups []string
+	for group, n := range k.nets {
+		if n == net {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func isTrigram(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeNavalIndicator builds the two 4-letter indicator groups of a naval
+// message, which were sent at its start and repeated at its end. The operator
+// wrote the Kenngruppe in a top row and the message key in a bottom row, each
+// padded with a filler letter on opposite sides:
+//
+//	F K K K
+//	S S S F
+//
+// and then replaced each vertical bigram using the bigram table. The new top
+// and bottom rows are the indicator groups.
+//
+// The message key here is the one as enciphered at the day's Grundstellung;
+// the message itself is encrypted with the rotors at the unenciphered key.
+func EncodeNavalIndicator(table *BigramTable, kenngruppe, key string, filler [2]byte) ([2]string, error) {
+	if !isTrigram(kenngruppe) || !isTrigram(key) {
+		return [2]string{}, fmt.Errorf(
+			"the Kenngruppe and message key must be 3 letters each. Got %q and %q", kenngruppe, key)
+	}
+	top := string(filler[0]) + kenngruppe
+	bottom := key + string(filler[1])
+	return substituteColumns(table, [2]string{top, bottom})
+}
+
+// DecodeNavalIndicator reverses EncodeNavalIndicator: it returns the
+// Kenngruppe and the (enciphered) message key in the indicator `groups`.
+func DecodeNavalIndicator(table *BigramTable, groups [2]string) (kenngruppe, key string, err error) {
+	if len(groups[0]) != 4 || len(groups[1]) != 4 {
+		return "", "", fmt.Errorf("indicator groups must be 4 letters each. Got %q", groups)
+	}
+	rows, err := substituteColumns(table, groups)
+	if err != nil {
+		return "", "", err
+	}
+	return rows[0][1:], rows[1][:3], nil
+}
+
+// substituteColumns replaces the vertical bigrams of two 4-letter rows using
+// `table`.
+func substituteColumns(table *BigramTable, rows [2]string) ([2]string, error) {
+	var top, bottom [4]byte
+	for i := 0; i < 4; i++ {
+		sub, err := table.Substitute(string([]byte{rows[0][i], rows[1][i]}))
+		if err != nil {
+			return [2]string{}, err
+		}
+		top[i], bottom[i] = sub[0], sub[1]
+	}
+	return [2]string{string(top[:]), string(bottom[:])}, nil
+}
@@ -0,0 +1,259 @@
+package enigma
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A KeySheet holds one day's settings for a key net, as the code books
+// listed them. Components are named as in Rotors and Reflectors, and plug
+// pairs are written like "AB".
+type KeySheet struct {
+	Reflector    string
+	Rotors       []string
+	RingSettings []byte
+	PlugPairs    []string
+}
+
+// GenerateKeySheet picks random settings for the named model (see Models),
+// with `plugPairs` plug pairs if the model has a plugboard.
+func GenerateKeySheet(rnd *rand.Rand, model string, plugPairs int) (KeySheet, error) {
+	m, ok := Models[model]
+	if !ok {
+		return KeySheet{}, fmt.Errorf("model %v does not exist; options are %v", model, ModelNames())
+	}
+	if plugPairs < 0 || 2*plugPairs > len(m.Alphabet) {
+		return KeySheet{}, fmt.Errorf("can't make %v plug pairs", plugPairs)
+	}
+	var key KeySheet
+	key.Reflector = m.Reflectors[rnd.Intn(len(m.Reflectors))]
+	// Not every choice of rotors fits (e.g. the M4 needs a thin rotor on the
+	// left), so keep choosing until one does.
+	for attempt := 0; ; attempt++ {
+		if attempt == 1000 {
+			return KeySheet{}, fmt.Errorf("could not find rotors that fit model %v", model)
+		}
+		key.Rotors = nil
+		for _, i := range rnd.Perm(len(m.Rotors))[:m.RotorSlots] {
+			key.Rotors = append(key.Rotors, m.Rotors[i])
+		}
+		if m.Validate(key.Reflector, key.Rotors) == nil {
+			break
+		}
+	}
+	key.RingSettings = make([]byte, m.RotorSlots)
+	for i := range key.RingSettings {
+		key.RingSettings[i] = m.Alphabet[rnd.Intn(len(m.Alphabet))]
+	}
+	if m.Plugboard {
+		letters := rnd.Perm(len(m.Alphabet))
+		for i := 0; i < plugPairs; i++ {
+			key.PlugPairs = append(key.PlugPairs,
+				string([]byte{m.Alphabet[letters[2*i]], m.Alphabet[letters[2*i+1]]}))
+		}
+	}
+	return key, nil
+}
+
+// NewMachine creates an Enigma of the named model, set up according to the
+// key sheet. Its rotors are left at the first position; set them to the
+// message key before typing.
+func (k KeySheet) NewMachine(model string) (Enigma, error) {
+	e, err := NewModel(model, k.Reflector, k.Rotors)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.SetRingSettings(k.RingSettings); err != nil {
+		return nil, err
+	}
+	plugboard, err := parsePlugPairs(k.PlugPairs)
+	if err != nil {
+		return nil, err
+	}
+	e.SetPlugboard(plugboard)
+	return e, nil
+}
+
+// A Network simulates the radio stations of a key net, which all share a key
+// sheet, sending each other messages. Transmissions can be garbled and
+// delayed on the way, as real ones were. Only models that type letters are
+// supported.
+type Network struct {
+	// The model of Enigma the stations use (see Models), and their key sheet.
+	Model string
+	Key   KeySheet
+
+	// The call signs of the stations, in letters.
+	Stations []string
+
+	// The chance that any one letter is garbled in transmission, and the
+	// chance that the sending operator mistypes any one letter of the
+	// plaintext, each from 0 to 1.
+	Noise, OperatorErrors float64
+
+	// The average time between messages, and the longest a message can take
+	// from being sent to being received, e.g. while waiting for a relay.
+	Interval, MaxDelay time.Duration
+
+	// The times of day (as time since midnight) at which messages are sent,
+	// one at each, day after day from the start. If given, Interval is
+	// ignored.
+	Schedule []time.Duration
+
+	// The bodies of the messages, in letters and spaces. Each message picks
+	// one at random. Defaults to a few stock texts.
+	Texts []string
+}
+
+// A Transmission is a message sent on a Network: what was heard on the air,
+// along with the ground truth and what the receiver made of it.
+type Transmission struct {
+	From, To       string
+	Sent, Received time.Time
+
+	// The indicator as sent in the clear: the start position (Grundstellung)
+	// the operator chose, followed by the message key as encrypted at that
+	// position. Both it and the Ciphertext are as received, garbles and all.
+	Indicator  string
+	Ciphertext string
+
+	// The message key and plaintext the sender used.
+	MessageKey string
+	Plaintext  string
+
+	// The receiver's decryption of the message.
+	Decrypted string
+
+	// Where the message comes from, for archives imported from elsewhere,
+	// e.g. the serial of a decrypt record (see ReadDecrypts). Empty for
+	// simulated messages.
+	Reference string
+}
+
+// trafficTexts are the bodies of the simulated messages.
+var trafficTexts = []string{
+	"KEINE BESONDEREN VORKOMMNISSE",
+	"ERBITTE NEUE BEFEHLE",
+	"STELLUNG GEHALTEN FEINDLICHE SPAEHTRUPPS ABGEWIESEN",
+	"TREIBSTOFF KNAPP ERBITTE NACHSCHUB",
+	"VERBAND MARSCHIERT WEITER NACH PLAN",
+	"FUNKVERKEHR WIRD UM ZWEI UHR EINGESTELLT",
+}
+
+// Simulate sends `messages` messages between random pairs of stations,
+// starting at `start`, and returns them as an archive, in the order they
+// were received. Each operator follows the indicator procedure in use from
+// 1940: choose a random start position and message key, send the start
+// position in the clear followed by the message key as encrypted at it, and
+// encrypt the message at the message key. Spaces are written as 'X'.
+// Messages longer than the model allows (see Model.MaxMessageLength) are
+// sent in parts, each with a key of its own, as consecutive transmissions.
+func (n Network) Simulate(rnd *rand.Rand, start time.Time, messages int) ([]Transmission, error) {
+	if m, ok := Models[n.Model]; ok && m.Alphabet != letters {
+		return nil, fmt.Errorf("can't simulate a network of %v, which doesn't type letters", n.Model)
+	}
+	if len(n.Stations) < 2 {
+		return nil, fmt.Errorf("a network needs at least 2 stations, got %v", n.Stations)
+	}
+	for _, station := range n.Stations {
+		if station == "" || strings.Trim(station, letters) != "" {
+			return nil, fmt.Errorf("%w: call signs must be letters A-Z, got %q", ErrBadLetter, station)
+		}
+	}
+	texts := n.Texts
+	if len(texts) == 0 {
+		texts = trafficTexts
+	}
+	for _, text := range texts {
+		if strings.Trim(text, letters+" ") != "" {
+			return nil, fmt.Errorf(
+				"%w: message texts must be letters A-Z and spaces, got %q", ErrBadLetter, text)
+		}
+	}
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	sender, err := n.Key.NewMachine(n.Model)
+	if err != nil {
+		return nil, err
+	}
+	receiver, _ := n.Key.NewMachine(n.Model)
+
+	var archive []Transmission
+	sent := start
+	for i := 0; i < messages; i++ {
+		var t Transmission
+		from := rnd.Intn(len(n.Stations))
+		to := (from + 1 + rnd.Intn(len(n.Stations)-1)) % len(n.Stations)
+		t.From, t.To = n.Stations[from], n.Stations[to]
+		if len(n.Schedule) > 0 {
+			day := midnight.AddDate(0, 0, i/len(n.Schedule))
+			sent = day.Add(n.Schedule[i%len(n.Schedule)])
+		} else if n.Interval > 0 {
+			sent = sent.Add(time.Duration(rnd.ExpFloat64() * float64(n.Interval)))
+		}
+		t.Sent, t.Received = sent, sent
+		if n.MaxDelay > 0 {
+			t.Received = sent.Add(time.Duration(rnd.Int63n(int64(n.MaxDelay))))
+		}
+
+		// Send the message, in parts if it is too long.
+		text := fmt.Sprintf("AN %v VON %v %v", t.To, t.From, texts[rnd.Intn(len(texts))])
+		t.Plaintext = strings.Replace(strings.Join(strings.Fields(text), " "), " ", "X", -1)
+		for _, part := range SplitMessage(t.Plaintext, Models[n.Model].MaxMessageLength) {
+			t.Plaintext = part
+			archive = append(archive, n.send(rnd, sender, receiver, t))
+		}
+	}
+	sort.SliceStable(archive, func(i, j int) bool {
+		return archive[i].Received.Before(archive[j].Received)
+	})
+	return archive, nil
+}
+
+// send has `sender` encrypt the plaintext of `t` at a fresh message key,
+// sends it over the air and has `receiver` decrypt it. Messages longer than
+// the model allows are sent in parts, each through send on its own.
+func (n Network) send(rnd *rand.Rand, sender, receiver Enigma, t Transmission) Transmission {
+	grundstellung := randomLetters(rnd, len(n.Key.Rotors))
+	t.MessageKey = randomLetters(rnd, len(n.Key.Rotors))
+	// The operators only ever choose and receive letters, so setting the
+	// rotors can't fail.
+	sender.SetRotorPositions([]byte(grundstellung))
+	encryptedKey := Type(sender, t.MessageKey)
+	sender.SetRotorPositions([]byte(t.MessageKey))
+	ciphertext := Type(sender, garble(rnd, t.Plaintext, n.OperatorErrors))
+
+	// Send it over the air.
+	t.Indicator = garble(rnd, grundstellung+encryptedKey, n.Noise)
+	t.Ciphertext = garble(rnd, ciphertext, n.Noise)
+
+	// Receive it.
+	positions := len(n.Key.Rotors)
+	receiver.SetRotorPositions([]byte(t.Indicator[:positions]))
+	key := Type(receiver, t.Indicator[positions:])
+	receiver.SetRotorPositions([]byte(key))
+	t.Decrypted = Type(receiver, t.Ciphertext)
+	return t
+}
+
+// randomLetters returns `n` random letters.
+func randomLetters(rnd *rand.Rand, n int) string {
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = letters[rnd.Intn(len(letters))]
+	}
+	return string(s)
+}
+
+// garble replaces each letter of `s` with another, with chance `noise`.
+func garble(rnd *rand.Rand, s string, noise float64) string {
+	b := []byte(s)
+	for i := range b {
+		if rnd.Float64() < noise {
+			b[i] = letters[(strings.IndexByte(letters, b[i])+1+rnd.Intn(len(letters)-1))%len(letters)]
+		}
+	}
+	return string(b)
+}
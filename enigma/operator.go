@@ -1,5 +1,11 @@
 package enigma
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // Type will press the `msg` sequence of keys on `e`, and returns
 // the sequence of lights that result.
 func Type(e Enigma, msg string) string {
@@ -16,3 +22,141 @@ func Type(e Enigma, msg string) string {
 	}
 	return string(buffer)
 }
+
+// TypeChecked presses the keys in `msg` on `e` like Type, but through
+// TryKeyPress, so lowercase letters are accepted and the machine is checked.
+// Anything else that isn't a key is replaced by `substitute`, the way
+// operators wrote 'X' for punctuation; if `substitute` is 0, it makes
+// TypeChecked stop with an error instead, returning the lights so far.
+func TypeChecked(e Enigma, msg string, substitute byte) (string, error) {
+	buffer := make([]byte, 0, len(msg))
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == ' ' {
+			buffer = append(buffer, ' ')
+			continue
+		}
+		light, err := e.TryKeyPress(msg[i])
+		if errors.Is(err, ErrBadLetter) && substitute != 0 {
+			light, err = e.TryKeyPress(substitute)
+		}
+		if err != nil {
+			return string(buffer), fmt.Errorf("character %v of the message: %w", i+1, err)
+		}
+		buffer = append(buffer, light)
+	}
+	return string(buffer), nil
+}
+
+// TypeTraced types `msg` on `e` like TypeChecked, but through TraceKeyPress,
+// calling `traced` with the trace of every key press as it happens. The
+// Trace is reused, so `traced` must copy anything it keeps.
+func TypeTraced(e Enigma, msg string, substitute byte, traced func(*Trace)) (string, error) {
+	trace := NewTrace(len(e.RotorPositions()))
+	buffer := make([]byte, 0, len(msg))
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == ' ' {
+			buffer = append(buffer, ' ')
+			continue
+		}
+		// Check the key first, so the machine only turns for a key that it
+		// can type.
+		key, ok, err := lookUpKey(e, msg[i])
+		if err == nil && !ok && substitute != 0 {
+			key, ok, err = lookUpKey(e, substitute)
+		}
+		if err == nil && !ok {
+			bad := msg[i]
+			if substitute != 0 {
+				bad = substitute
+			}
+			_, err = checkKey(e, bad)
+		}
+		if err != nil {
+			return string(buffer), fmt.Errorf("character %v of the message: %w", i+1, err)
+		}
+		buffer = append(buffer, e.TraceKeyPress(key, trace))
+		traced(trace)
+	}
+	return string(buffer), nil
+}
+
+// A keyChecker can tell whether a key can be typed without typing it. The
+// machines of this package are keyCheckers.
+type keyChecker interface {
+	checkKey(k byte) (byte, error)
+	lookUpKey(k byte) (byte, bool, error)
+}
+
+// checkKey returns the key that `k` stands for on `e`, or the error that
+// TryKeyPress would return for it, without turning the rotors. Other
+// implementations of Enigma are checked on a copy.
+func checkKey(e Enigma, k byte) (byte, error) {
+	if c, ok := e.(keyChecker); ok {
+		return c.checkKey(k)
+	}
+	if _, err := e.CloneState().TryKeyPress(k); err != nil {
+		return 0, err
+	}
+	if k >= 'a' && k <= 'z' {
+		k = k - 'a' + 'A'
+	}
+	return k, nil
+}
+
+// lookUpKey returns the key that `k` stands for on `e`, and whether there is
+// one, or an error if `e` can't type at all. For the machines of this
+// package, it doesn't allocate.
+func lookUpKey(e Enigma, k byte) (byte, bool, error) {
+	if c, ok := e.(keyChecker); ok {
+		return c.lookUpKey(k)
+	}
+	key, err := checkKey(e, k)
+	if errors.Is(err, ErrBadLetter) {
+		return 0, false, nil
+	}
+	return key, err == nil, err
+}
+
+// messageLength returns the number of letters in `msg`, not counting spaces.
+func messageLength(msg string) int {
+	return len(msg) - strings.Count(msg, " ")
+}
+
+// CheckMessageLength returns an error wrapping ErrMessageTooLong if `msg` has
+// more than `max` letters, not counting spaces. A `max` of 0 means no limit.
+func CheckMessageLength(msg string, max int) error {
+	if n := messageLength(msg); max > 0 && n > max {
+		return fmt.Errorf("%w: %v letters, but at most %v are allowed; split it into parts",
+			ErrMessageTooLong, n, max)
+	}
+	return nil
+}
+
+// SplitMessage splits `msg` into parts of at most `max` letters each, not
+// counting spaces, as procedure required for long messages. Each part was
+// sent as a message of its own, with its own message key. Parts end between
+// groups where they can; groups are separated by single spaces. A `max` of 0
+// means no limit.
+func SplitMessage(msg string, max int) []string {
+	groups := strings.Fields(msg)
+	if max <= 0 || messageLength(strings.Join(groups, " ")) <= max {
+		return []string{strings.Join(groups, " ")}
+	}
+	var parts []string
+	var part []string
+	letters := 0
+	for _, group := range groups {
+		if letters > 0 && letters+len(group) > max {
+			parts = append(parts, strings.Join(part, " "))
+			part, letters = nil, 0
+		}
+		// A group too long for a part of its own is cut.
+		for len(group) > max {
+			parts = append(parts, group[:max])
+			group = group[max:]
+		}
+		part = append(part, group)
+		letters += len(group)
+	}
+	return append(parts, strings.Join(part, " "))
+}
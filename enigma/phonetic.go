@@ -0,0 +1,74 @@
+package enigma
+
+import (
+	"fmt"
+	"strings"
+)
+
+// phoneticAlphabet is the German spelling alphabet of the late 1930s, used to
+// read letters aloud over voice links, in alphabetical order.
+var phoneticAlphabet = [numLetters]string{
+	"Anton", "Berta", "Cäsar", "Dora", "Emil", "Friedrich", "Gustav", "Heinrich", "Ida",
+	"Julius", "Kaufmann", "Ludwig", "Martha", "Nordpol", "Otto", "Paula", "Quelle",
+	"Richard", "Siegfried", "Toni", "Ulrich", "Viktor", "Wilhelm", "Xanthippe",
+	"Ypsilon", "Zeppelin",
+}
+
+// phoneticGroupSeparator separates groups in spelled-out text.
+const phoneticGroupSeparator = "/"
+
+// SpellPhonetic spells out `text`, a message of letters A-Z in groups
+// separated by spaces, in the German spelling alphabet (Anton, Berta, Cäsar,
+// ...). Groups are separated by " / ". Anything that isn't a letter is
+// dropped.
+func SpellPhonetic(text string) string {
+	var groups []string
+	for _, group := range strings.Fields(text) {
+		var words []string
+		for i := 0; i < len(group); i++ {
+			if group[i] >= 'A' && group[i] <= 'Z' {
+				words = append(words, phoneticAlphabet[group[i]-'A'])
+			}
+		}
+		if len(words) > 0 {
+			groups = append(groups, strings.Join(words, " "))
+		}
+	}
+	return strings.Join(groups, " "+phoneticGroupSeparator+" ")
+}
+
+// ParsePhonetic is the reverse of SpellPhonetic: it turns words of the German
+// spelling alphabet back into letters, in groups separated by "/". Words are
+// matched regardless of case, and "Caesar" and "Casar" are accepted for
+// "Cäsar". An error is returned for any word that isn't in the alphabet.
+func ParsePhonetic(spoken string) (string, error) {
+	var groups []string
+	for _, part := range strings.Split(spoken, phoneticGroupSeparator) {
+		var group strings.Builder
+		for _, word := range strings.Fields(part) {
+			letter, ok := phoneticLetter(word)
+			if !ok {
+				return "", fmt.Errorf("%q is not in the spelling alphabet", word)
+			}
+			group.WriteByte(letter)
+		}
+		if group.Len() > 0 {
+			groups = append(groups, group.String())
+		}
+	}
+	return strings.Join(groups, " "), nil
+}
+
+// phoneticLetter returns the letter that `word` spells, if any.
+func phoneticLetter(word string) (byte, bool) {
+	switch strings.ToLower(word) {
+	case "caesar", "casar":
+		return 'C', true
+	}
+	for i, w := range phoneticAlphabet {
+		if strings.EqualFold(w, word) {
+			return byte(i) + 'A', true
+		}
+	}
+	return 0, false
+}
@@ -1,9 +1,6 @@
 package enigma
 
-import (
-	"fmt"
-	"log"
-)
+import "fmt"
 
 // A Plugboard is much like a Reflector, in that it maps two letters to each
 // other, and if 'A' maps to 'B', 'B' must map to 'A'. However, unlike a
@@ -19,13 +16,18 @@ func (p *Plugboard) AddPlugPair(left, right byte) error {
 		p.mapping = make(map[byte]byte)
 	}
 
+	for _, plug := range []byte{left, right} {
+		if !Letters.Contains(plug) {
+			return fmt.Errorf("%w: plugs are letters A-Z, got %q", ErrBadLetter, plug)
+		}
+	}
 	if prev, mapped := p.mapping[left]; mapped {
-		return fmt.Errorf(
-			"Plug %q can't be mapped to %q, it was previously mapped to %q", left, right, prev)
+		return fmt.Errorf("%w: %q can't be mapped to %q, it was previously mapped to %q",
+			ErrPlugConflict, left, right, prev)
 	}
 	if prev, mapped := p.mapping[right]; mapped {
-		return fmt.Errorf(
-			"Plug %q can't be mapped to %q, it was previously mapped to %q", right, left, prev)
+		return fmt.Errorf("%w: %q can't be mapped to %q, it was previously mapped to %q",
+			ErrPlugConflict, right, left, prev)
 	}
 
 	p.mapping[left] = right
@@ -46,18 +48,55 @@ func (p *Plugboard) mapLetter(letter byte) byte {
 	return output
 }
 
+// count returns the number of plug pairs.
+func (p *Plugboard) count() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.mapping) / 2
+}
+
+// pairs returns the plug pairs, written like "AB", in alphabetical order.
+func (p *Plugboard) pairs() []string {
+	var pairs []string
+	if p == nil {
+		return pairs
+	}
+	for _, l := range []byte(letters) {
+		if r, mapped := p.mapping[l]; mapped && l < r {
+			pairs = append(pairs, string([]byte{l, r}))
+		}
+	}
+	return pairs
+}
+
+// parsePlugPairs creates a Plugboard from plug pairs written like "AB".
+func parsePlugPairs(pairs []string) (Plugboard, error) {
+	var plugboard Plugboard
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			return Plugboard{}, fmt.Errorf("plug pairs must be 2 letters, such as 'AB'. Got %q", pair)
+		}
+		if err := plugboard.AddPlugPair(pair[0], pair[1]); err != nil {
+			return Plugboard{}, err
+		}
+	}
+	return plugboard, nil
+}
+
 // Pair represents a pair of letters to be mapped on a plugboard.
 type Pair struct {
-	left, right byte
+	Left, Right byte
 }
 
-// MakePlugboard creates a Plugboard that has the given mappings.
-func makePlugboard(pairs []Pair) Plugboard {
+// MakePlugboard creates a Plugboard that has the given mappings, or returns an
+// error if any letter is in more than one of them.
+func MakePlugboard(pairs []Pair) (Plugboard, error) {
 	var plugboard Plugboard
 	for _, pair := range pairs {
-		if err := plugboard.AddPlugPair(pair.left, pair.right); err != nil {
-			log.Fatalf("%s", err)
+		if err := plugboard.AddPlugPair(pair.Left, pair.Right); err != nil {
+			return Plugboard{}, err
 		}
 	}
-	return plugboard
+	return plugboard, nil
 }
@@ -2,16 +2,18 @@ package enigma
 
 import (
 	"fmt"
-	"log"
 	"sort"
 )
 
-// Reflectors is the set of Enigma reflectors that were originally available to the Enigma I.
-var Reflectors = map[string]Reflector{
-	"A": makeReflectorOrDie("EJMZALYXVBWFCRQUONTSPIKHGD"),
-	"B": makeReflectorOrDie("YRUHQSLDPXNGOKMIEBFZCWVJAT"),
-	"C": makeReflectorOrDie("FVPJIAOYEDRZXWGCTKUQSBNMHL"),
-}
+// Reflectors is the set of Enigma reflectors that were originally available to the Enigma I,
+// plus the thin reflectors that the M4 used alongside its Greek rotors, and the
+// settable reflectors of the Enigma G (see NewG), the Enigma K (see NewK) and the
+// Enigma T (see NewT), and the 10-contact reflector of the Enigma Z (see NewZ).
+// Reflector "N" is the one of postwar Norway's rewired Enigma I machines.
+//
+// Reflectors is built from the catalog; see LookupReflector and
+// ListReflectors for what is known about each reflector.
+var Reflectors = catalogReflectors()
 
 // ReflectorNames returns the names of the available reflectors, as a sorted slice of strings.
 func ReflectorNames() []string {
@@ -26,18 +28,22 @@ func ReflectorNames() []string {
 }
 
 // makeReflector turns a compact string representation of a reflector's internal
-// wiring into an actual Reflector. In the string representation, position 0
-// represents 'A', and its value represents the letter that 'A' connects to.
-// Position 1 represents 'B', and so forth.
-func makeReflector(s string) (*Reflector, error) {
-	var r Reflector
-	if len(s) != len(r.mapping) {
+// wiring into an actual Reflector, whose contacts are labeled with `alphabet`.
+// In the string representation, position 0 represents the first label (e.g.
+// 'A'), and its value represents the label that it connects to. Position 1
+// represents the second label, and so forth.
+func makeReflector(alphabet Alphabet, s string) (*Reflector, error) {
+	if err := alphabet.check(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidReflector, err)
+	}
+	r := Reflector{alphabet: alphabet}
+	if len(s) != len(alphabet) {
 		return nil, fmt.Errorf(
-			"could not create reflector: input %v is not length %v but length %v",
-			s, len(r.mapping), len(s))
+			"%w: input %v is not length %v but length %v",
+			ErrInvalidReflector, s, len(alphabet), len(s))
 	}
 	for i := 0; i < len(s); i++ {
-		r.mapping[i] = s[i] - 'A'
+		r.mapping[i] = byte(alphabet.Index(s[i]))
 	}
 	if err := ValidateReflector(r); err != nil {
 		return nil, err
@@ -45,36 +51,68 @@ func makeReflector(s string) (*Reflector, error) {
 	return &r, nil
 }
 
-// makeReflectorOrDie does the same as makeReflector, but instead of returning
-// errors will kill the process in case of trouble.
-func makeReflectorOrDie(s string) Reflector {
-	r, err := makeReflector(s)
+// MakeAlphabetReflector turns a compact string representation of a
+// reflector's wiring into a Reflector whose contacts are labeled with the
+// symbols of `alphabet`, for a machine created with NewWithAlphabet.
+func MakeAlphabetReflector(alphabet Alphabet, s string) (*Reflector, error) {
+	return makeReflector(alphabet, s)
+}
+
+// mustMakeReflector does the same as makeReflector, but panics on errors, so
+// it is only for the wirings built into this package.
+func mustMakeReflector(s string) Reflector {
+	r, err := makeReflector(letters, s)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 	return *r
 }
 
+// mustMakeDigitReflector creates a 10-contact reflector for the Enigma Z,
+// labeled with digits, like mustMakeReflector does.
+func mustMakeDigitReflector(s string) Reflector {
+	r, err := makeReflector(digits, s)
+	if err != nil {
+		panic(err)
+	}
+	return *r
+}
+
+// mustMakeThinReflector creates a thin reflector (see Reflector.thin) from a
+// compact string representation of its wiring, like mustMakeReflector does.
+func mustMakeThinReflector(s string) Reflector {
+	r := mustMakeReflector(s)
+	r.thin = true
+	return r
+}
+
+// Thin returns whether this is a thin reflector, which only fits in an M4
+// alongside a thin rotor.
+func (r Reflector) Thin() bool {
+	return r.thin
+}
+
 // ValidateReflector returns `nil` if the given Reflector is valid, or an error
 // otherwise.
 func ValidateReflector(r Reflector) error {
-	for i := 0; i < len(r.mapping); i++ {
-		if r.mapping[i] < 0 || int(r.mapping[i]) > len(r.mapping) {
+	contacts := len(r.alphabet)
+	for i := 0; i < contacts; i++ {
+		if int(r.mapping[i]) >= contacts {
 			return fmt.Errorf(
-				"invalid reflector %v: position %v has invalid value %v (letter %q)",
-				r.mapping, i, r.mapping[i], byte(r.mapping[i]+'A'))
+				"%w %v: position %v has invalid value %v", ErrInvalidReflector,
+				r.mapping[:contacts], i, r.mapping[i])
 		}
 		to := r.mapping[i]
 		if int(to) == i {
 			return fmt.Errorf(
-				"invalid reflector %v: position %v (letter-position %q) maps to itself",
-				r.mapping, i, byte(i+'A'))
+				"%w %v: position %v (%q) maps to itself", ErrInvalidReflector,
+				r.mapping[:contacts], i, r.alphabet[i])
 		}
 		if int(r.mapping[to]) != i {
 			return fmt.Errorf(
-				"invalid reflector %v: %q maps to %q, but %q maps to %q",
-				r.mapping, byte(i+'A'), byte(to+'A'), byte(to+'A'),
-				byte(r.mapping[to]+'A'))
+				"%w %v: %q maps to %q, but %q maps to %q", ErrInvalidReflector,
+				r.mapping[:contacts], r.alphabet[i], r.alphabet[to], r.alphabet[to],
+				r.alphabet[r.mapping[to]])
 		}
 	}
 	return nil
@@ -0,0 +1,76 @@
+package enigma
+
+import "fmt"
+
+// CustomFamily is the Family of the components added with RegisterRotor and
+// RegisterReflector.
+const CustomFamily = "Custom"
+
+// RegisterRotor adds a rotor called `name`, with the given wiring and
+// turnover points (see MakeRotor), to Rotors and the catalog. Custom rotors
+// fit every model whose keys are letters.
+//
+// Rotors isn't safe for concurrent use, so register components before
+// creating machines, e.g. from an init function.
+func RegisterRotor(name, wiring string, notches ...byte) error {
+	if name == "" {
+		return fmt.Errorf("could not register rotor: it has no name")
+	}
+	if _, exists := Rotors[name]; exists {
+		return fmt.Errorf("could not register rotor %v: a rotor by that name already exists", name)
+	}
+	r, err := MakeRotor(wiring, notches...)
+	if err != nil {
+		return fmt.Errorf("could not register rotor %v: %w", name, err)
+	}
+	Rotors[name] = *r
+	rotorCatalog = append(rotorCatalog, RotorInfo{
+		Name: name, Family: CustomFamily, Wiring: wiring, Notches: string(notches),
+	})
+	return nil
+}
+
+// RegisterReflector adds a reflector called `name`, with the given wiring
+// (written like a rotor's), to Reflectors and the catalog, like
+// RegisterRotor.
+func RegisterReflector(name, wiring string) error {
+	if name == "" {
+		return fmt.Errorf("could not register reflector: it has no name")
+	}
+	if _, exists := Reflectors[name]; exists {
+		return fmt.Errorf("could not register reflector %v: a reflector by that name already exists", name)
+	}
+	r, err := makeReflector(letters, wiring)
+	if err != nil {
+		return fmt.Errorf("could not register reflector %v: %w", name, err)
+	}
+	Reflectors[name] = *r
+	reflectorCatalog = append(reflectorCatalog, ReflectorInfo{
+		Name: name, Family: CustomFamily, Wiring: wiring,
+	})
+	return nil
+}
+
+// customRotors returns the names of the registered rotors, in the order they
+// were registered.
+func customRotors() []string {
+	var names []string
+	for _, info := range rotorCatalog {
+		if info.Family == CustomFamily {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
+
+// customReflectors returns the names of the registered reflectors, like
+// customRotors.
+func customReflectors() []string {
+	var names []string
+	for _, info := range reflectorCatalog {
+		if info.Family == CustomFamily {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
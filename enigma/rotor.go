@@ -2,18 +2,23 @@ package enigma
 
 import (
 	"fmt"
-	"log"
 	"sort"
 )
 
-// Rotors is the set of Enigma rotors that were originally available to the Enigma I.
-var Rotors = map[string]Rotor{
-	"I":   makeRotorOrDie("EKMFLGDQVZNTOWYHXUSPAIBRCJ", 'Q'),
-	"II":  makeRotorOrDie("AJDKSIRUXBLHWTMCQGZNPYFVOE", 'E'),
-	"III": makeRotorOrDie("BDFHJLCPRTXVZNYEIWGAKMUSQO", 'V'),
-	"IV":  makeRotorOrDie("ESOVPZJAYQUIRHXLNFTGKDCMWB", 'J'),
-	"V":   makeRotorOrDie("VZBRGITYUPSDNHLXAWMJQOFECK", 'Z'),
-}
+// Rotors is the set of available Enigma rotors: the rotors originally available
+// to the Enigma I, plus the rotors that the Kriegsmarine added for its M3 and
+// M4: rotors VI through VIII, which have two notches each, and the thin "Greek"
+// rotors of the M4. The rotors of the Abwehr's Enigma G (see NewG) are named
+// "G-I" through "G-III", and those of the commercial Enigma K (see NewK) "K-I"
+// through "K-III". The eight rotors of the Enigma T (see NewT) are "T-I"
+// through "T-VIII", and the 10-contact rotors of the Enigma Z (see NewZ) "Z-I"
+// through "Z-III". The rotors that postwar Norway rewired its Enigma I machines
+// with (the "Norenigma") are "N-I" through "N-V", and the rotors that
+// Switzerland rewired its Enigma K machines with are "SK-I" through "SK-III".
+//
+// Rotors is built from the catalog; see LookupRotor and ListRotors for what is
+// known about each rotor.
+var Rotors = catalogRotors()
 
 // RotorNames returns the names of the available rotors, as a sorted slice of strings.
 func RotorNames() []string {
@@ -27,84 +32,256 @@ func RotorNames() []string {
 	return names
 }
 
-// Rotor represents the configuration of a single Enigma rotor.
-type Rotor struct {
-	// Every rotor has 26 contacts on both the left and the right side.
-	// Each of the 26 contacts on one side is connected to exactly one
-	// contact on the other side. The mapping below expresses those
+// Rotor is a single Enigma rotor, as the machine sees it: a set of contacts
+// on either side, connected in some way, with notches that turn over its
+// left neighbour. WiredRotor is the standard implementation; others, such as
+// research rotors, can be installed alongside it.
+type Rotor interface {
+	// Map returns the contact that `contact` connects to on the other side of
+	// the rotor. Contacts are numbered 0 to len(Alphabet())-1, relative to
+	// the rotor's wiring. If `forward`, the signal goes right to left, towards
+	// the reflector; otherwise it goes left to right, on its way back.
+	Map(forward bool, contact byte) byte
+
+	// Notched returns whether the rotor turns over its left neighbour when it
+	// is at position `pos` (0 for 'A', and so forth).
+	Notched(pos byte) bool
+
+	// Thin returns whether this is a thin rotor, which only fits in the
+	// leftmost slot of an M4, where it never turns.
+	Thin() bool
+
+	// Alphabet returns the labels of the rotor's contacts, in order: the
+	// letters A-Z, or for the Enigma Z the digits 1-9 and 0. A rotor only
+	// fits in a machine with the same alphabet.
+	Alphabet() Alphabet
+}
+
+// WiredRotor is the standard implementation of Rotor: the configuration of a
+// single Enigma rotor with fixed wiring.
+type WiredRotor struct {
+	// The labels of the rotor's contacts, in order. Most rotors are labeled
+	// with the 26 letters; those of the Enigma Z with 10 digits. See Alphabet.
+	alphabet Alphabet
+
+	// Every rotor has 26 (or, on the Enigma Z, 10) contacts on both the left
+	// and the right side. Each contact on one side is connected to exactly
+	// one contact on the other side. The mapping below expresses those
 	// connections.
 	//
 	// Each contact has an index 0-25 that identifies its position on
 	// its side of the rotor. The mapping below indicates which 'right'
 	// contact is connected to which 'left' contact; this is the usual
 	// mapping found to describe an Enigma rotor. To convert from the
-	// string-based format that mapping is normally found in, use the
-	// makeRotor() method in 'util.go'. To check that your resulting
-	// rotor makes sense, use ValidateRotor().
-	rlMapping [numLetters]byte
+	// string-based format that mapping is normally found in, use
+	// MakeRotor(). To check that your resulting rotor makes sense, use
+	// ValidateRotor().
+	rlMapping [maxContacts]byte
+
+	// The signal passes through the rotor in both directions, so we also keep
+	// the inverse of rlMapping.
+	lrMapping [maxContacts]byte
 
 	// Every rotor has different points at which it "turns over"
 	// (causes the next rotor to advance one position). This mapping
 	// indicates whether a given point is such a turnover point.
-	turnoverPoints [numLetters]bool
+	turnoverPoints [maxContacts]bool
+
+	// Thin rotors (the M4's "Greek" rotors) sit in the leftmost position,
+	// next to a thin reflector. They never turn during operation.
+	thin bool
 }
 
 // Reflector represents the configuration of a single Engima reflector.
 type Reflector struct {
+	// The labels of the reflector's contacts, in order, like
+	// WiredRotor.alphabet.
+	alphabet Alphabet
+
 	// The reflector, unlike a rotor, has contacts on only one side,
 	// and thus maps between contacts on the same side. If 'A' maps
 	// to 'B', 'B' therefore must also map to 'A'.
-	mapping [numLetters]byte
+	mapping [maxContacts]byte
+
+	// Thin reflectors make room for a thin rotor in an M4. They can't be
+	// used without one.
+	thin bool
 }
 
-// makeRotor turns a compact string representation of a rotor's internal wiring
+// MakeRotor turns a compact string representation of a rotor's internal wiring
 // into an actual Rotor. In the string representation, position 0 represents
 // 'A', and its value represents the letter that 'A' connects to. Position 1
 // represents 'B', and so forth.
-func makeRotor(s string, turnoverPoint byte) (*Rotor, error) {
-	var r Rotor
-	if len(s) != len(r.rlMapping) {
+//
+// The `turnoverPoints` are the letters at which the rotor turns over its left
+// neighbour; most rotors have one, rotors VI through VIII have two, and the
+// Enigma G's rotors have many. A rotor without turnover points never turns
+// over its neighbour.
+func MakeRotor(s string, turnoverPoints ...byte) (*WiredRotor, error) {
+	return makeRotor(letters, s, turnoverPoints)
+}
+
+// MakeAlphabetRotor does the same as MakeRotor, for a rotor whose contacts are
+// labeled with the symbols of `alphabet`, for a machine created with
+// NewWithAlphabet. Its wiring and turnover points are written in those
+// symbols.
+func MakeAlphabetRotor(alphabet Alphabet, s string, turnoverPoints ...byte) (*WiredRotor, error) {
+	return makeRotor(alphabet, s, turnoverPoints)
+}
+
+// makeRotor does the same as MakeRotor, for a rotor whose contacts are
+// labeled with `alphabet`.
+func makeRotor(alphabet Alphabet, s string, turnoverPoints []byte) (*WiredRotor, error) {
+	if err := alphabet.check(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRotor, err)
+	}
+	r := WiredRotor{alphabet: alphabet}
+	if len(s) != len(alphabet) {
 		return nil, fmt.Errorf(
-			"could not create rotor: input %v is not of length %v but of length %v",
-			s, len(r.rlMapping), len(s))
+			"%w: input %v is not of length %v but of length %v",
+			ErrInvalidRotor, s, len(alphabet), len(s))
 	}
 	for i := 0; i < len(s); i++ {
-		r.rlMapping[i] = s[i] - 'A'
+		to := alphabet.Index(s[i])
+		if to < 0 {
+			return nil, fmt.Errorf("%w: %q in %v is not one of %v", ErrInvalidRotor, s[i], s, alphabet)
+		}
+		r.rlMapping[i] = byte(to)
+		r.lrMapping[to] = byte(i)
+	}
+	for _, p := range turnoverPoints {
+		point := alphabet.Index(p)
+		if point < 0 {
+			return nil, fmt.Errorf(
+				"%w: turnover point %q is not one of %v", ErrInvalidRotor, p, alphabet)
+		}
+		if r.turnoverPoints[point] {
+			return nil, fmt.Errorf("%w: turnover point %q is listed twice", ErrInvalidRotor, p)
+		}
+		r.turnoverPoints[point] = true
 	}
-	r.turnoverPoints[turnoverPoint-'A'] = true
 	if err := ValidateRotor(r); err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
-// makeRotorOrDie does the same as makeRotor, but instead of returning errors
-// will kill the process in case of trouble.
-func makeRotorOrDie(s string, turnoverPoint byte) Rotor {
-	r, err := makeRotor(s, turnoverPoint)
+// mustMakeRotor does the same as MakeRotor, but panics on errors, so it is
+// only for the wirings built into this package, which the tests check. For
+// compactness, it takes the turnover points as a string.
+func mustMakeRotor(s string, turnoverPoints string) WiredRotor {
+	r, err := MakeRotor(s, []byte(turnoverPoints)...)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 	return *r
 }
 
+// mustMakeDigitRotor creates a 10-contact rotor for the Enigma Z, labeled
+// with digits, like mustMakeRotor does.
+func mustMakeDigitRotor(s string, turnoverPoints string) WiredRotor {
+	r, err := makeRotor(digits, s, []byte(turnoverPoints))
+	if err != nil {
+		panic(err)
+	}
+	return *r
+}
+
+// mustMakeGreekRotor creates a thin rotor (see WiredRotor.thin) from a
+// compact string representation of its wiring, like mustMakeRotor does.
+func mustMakeGreekRotor(s string) WiredRotor {
+	// Greek rotors have no notches; they never turn, and there's never a rotor
+	// to their left to turn.
+	r := mustMakeRotor(s, "")
+	r.thin = true
+	return r
+}
+
+// contacts returns the number of contacts on each side of the rotor.
+func (r WiredRotor) contacts() uint8 {
+	return uint8(len(r.alphabet))
+}
+
+// Map implements Rotor.
+func (r WiredRotor) Map(forward bool, contact byte) byte {
+	if forward {
+		return r.rlMapping[contact]
+	}
+	return r.lrMapping[contact]
+}
+
+// Notched implements Rotor.
+func (r WiredRotor) Notched(pos byte) bool {
+	return r.turnoverPoints[pos]
+}
+
+// Thin implements Rotor.
+func (r WiredRotor) Thin() bool {
+	return r.thin
+}
+
+// Alphabet implements Rotor.
+func (r WiredRotor) Alphabet() Alphabet {
+	return r.alphabet
+}
+
+// m4Rotors is the number of rotors in an M4, the leftmost of which is thin.
+const m4Rotors = 4
+
+// ValidateSpindle returns `nil` if the given reflector and rotors (listed
+// left-to-right) fit together on an Enigma's spindle, or an error otherwise.
+// Thin rotors and reflectors only fit in an M4: the thin rotor must be the
+// leftmost of its 4 rotors, next to a thin reflector, and only a thin rotor
+// fits in that slot.
+func ValidateSpindle(reflector Reflector, rotors []Rotor) error {
+	for i, r := range rotors {
+		if r.Thin() && (i != 0 || len(rotors) != m4Rotors) {
+			return fmt.Errorf(
+				"invalid spindle: thin rotor in position %v of %v; it only fits in the leftmost of %v",
+				i+1, len(rotors), m4Rotors)
+		}
+	}
+	if len(rotors) == m4Rotors && !rotors[0].Thin() {
+		return fmt.Errorf("invalid spindle: the leftmost of %v rotors must be a thin rotor", m4Rotors)
+	}
+	for _, r := range rotors {
+		if r.Alphabet() != reflector.alphabet {
+			return fmt.Errorf(
+				"invalid spindle: a rotor labeled %v doesn't fit with a reflector labeled %v",
+				r.Alphabet(), reflector.alphabet)
+		}
+	}
+	hasThinRotor := len(rotors) > 0 && rotors[0].Thin()
+	if reflector.thin != hasThinRotor {
+		return fmt.Errorf("invalid spindle: thin reflectors must be used with a thin rotor, and vice versa")
+	}
+	return nil
+}
+
 // ValidateRotor returns `nil` if the given Rotor is valid, or an error
-// otherwise.
+// otherwise. A valid rotor connects each contact to exactly one contact on
+// the other side, the same way in both directions.
 func ValidateRotor(r Rotor) error {
-	var seen [numLetters]bool
-	for i := 0; i < len(r.rlMapping); i++ {
-		if r.rlMapping[i] < 0 || int(r.rlMapping[i]) > len(r.rlMapping) {
+	contacts := len(r.Alphabet())
+	if contacts == 0 || contacts > maxContacts {
+		return fmt.Errorf("%w: it has %v contacts, but must have 1-%v",
+			ErrInvalidRotor, contacts, maxContacts)
+	}
+	var seen [maxContacts]bool
+	for i := 0; i < contacts; i++ {
+		to := r.Map(true, byte(i))
+		if int(to) >= contacts {
+			return fmt.Errorf("%w: position %v has invalid value %v", ErrInvalidRotor, i, to)
+		}
+		if seen[to] {
 			return fmt.Errorf(
-				"invalid rotor %v: position %v has invalid value %v (letter %q)",
-				r.rlMapping, i, r.rlMapping[i], byte(r.rlMapping[i]+'A'))
+				"%w: value %v (%q) appears twice", ErrInvalidRotor, to, r.Alphabet()[to])
 		}
-		seen[r.rlMapping[i]] = true
-	}
-	for i, present := range seen {
-		if !present {
+		seen[to] = true
+		if back := r.Map(false, to); int(back) != i {
 			return fmt.Errorf(
-				"invalid rotor %v: value %v (letter %q) is missing",
-				r.rlMapping, i, byte(i+'A'))
+				"%w: %q maps to %q, but %q maps back to %q", ErrInvalidRotor,
+				r.Alphabet()[i], r.Alphabet()[to], r.Alphabet()[to], r.Alphabet()[back])
 		}
 	}
 	return nil
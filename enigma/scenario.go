@@ -0,0 +1,198 @@
+package enigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Scenario files script a Network simulation in JSON. Times of day are
+// written like "08:00", and durations like "90m" (see time.ParseDuration).
+// For example:
+//
+//	{
+//	  "model": "I",
+//	  "start": "1941-05-01T08:00:00Z",
+//	  "messages": 20,
+//	  "plugPairs": 10,
+//	  "stations": ["KOELN", "BERLIN", "WIEN"],
+//	  "schedule": ["08:00", "12:00", "18:00"],
+//	  "maxDelay": "1h",
+//	  "noise": 0.01,
+//	  "operatorErrors": 0.005,
+//	  "texts": ["KEINE BESONDEREN VORKOMMNISSE"],
+//	  "seed": 1
+//	}
+//
+// Instead of a schedule, "interval" gives the average time between messages.
+// Every field but "stations" and "messages" is optional.
+type scenarioFile struct {
+	Model          string
+	Start          time.Time
+	Messages       int
+	PlugPairs      *int
+	Stations       []string
+	Schedule       []string
+	Interval       string
+	MaxDelay       string
+	Noise          float64
+	OperatorErrors float64
+	Texts          []string
+	Seed           int64
+}
+
+// A Scenario is a scripted Network simulation: the network, which messages
+// to send on it, and the seed that makes the run repeatable. The network's
+// key sheet is generated when the scenario is run.
+type Scenario struct {
+	Network
+
+	// When the first message is sent, and how many are sent.
+	Start    time.Time
+	Messages int
+
+	// The number of plug pairs on the generated key sheet.
+	PlugPairs int
+
+	// The seed for the random choices.
+	Seed int64
+}
+
+// ReadScenario reads a Scenario in the file format above. The model defaults
+// to the Enigma I, the plug pairs to 10 and the start to the start of 1941.
+func ReadScenario(r io.Reader) (*Scenario, error) {
+	var file scenarioFile
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("could not read scenario: %v", err)
+	}
+
+	s := Scenario{
+		Network: Network{
+			Model:          file.Model,
+			Stations:       file.Stations,
+			Noise:          file.Noise,
+			OperatorErrors: file.OperatorErrors,
+			Texts:          file.Texts,
+		},
+		Start:     file.Start,
+		Messages:  file.Messages,
+		PlugPairs: 10,
+		Seed:      file.Seed,
+	}
+	if s.Model == "" {
+		s.Model = "I"
+	}
+	if file.PlugPairs != nil {
+		s.PlugPairs = *file.PlugPairs
+	}
+	if s.Start.IsZero() {
+		s.Start = date(1941, time.January, 1)
+	}
+	if s.Messages <= 0 {
+		return nil, fmt.Errorf("a scenario needs messages to send, got %v", s.Messages)
+	}
+	for _, p := range []float64{s.Noise, s.OperatorErrors} {
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("chances must be from 0 to 1, got %v", p)
+		}
+	}
+	for _, at := range file.Schedule {
+		t, err := time.Parse("15:04", at)
+		if err != nil {
+			return nil, fmt.Errorf("schedule times must be like '08:00', got %q", at)
+		}
+		sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		s.Schedule = append(s.Schedule, sinceMidnight)
+	}
+	var err error
+	if file.Interval != "" {
+		if s.Interval, err = time.ParseDuration(file.Interval); err != nil {
+			return nil, fmt.Errorf("invalid interval: %v", err)
+		}
+	}
+	if file.MaxDelay != "" {
+		if s.MaxDelay, err = time.ParseDuration(file.MaxDelay); err != nil {
+			return nil, fmt.Errorf("invalid maxDelay: %v", err)
+		}
+	}
+	return &s, nil
+}
+
+// Run generates the scenario's key sheet, which it stores in s.Key, and
+// simulates its network. It returns the resulting archive (see
+// Network.Simulate). Running a scenario again gives the same results.
+func (s *Scenario) Run() ([]Transmission, error) {
+	rnd := rand.New(rand.NewSource(s.Seed))
+	key, err := GenerateKeySheet(rnd, s.Model, s.PlugPairs)
+	if err != nil {
+		return nil, err
+	}
+	s.Key = key
+	return s.Simulate(rnd, s.Start, s.Messages)
+}
+
+// archiveGroupSize is the size of the groups that WriteArchive writes
+// ciphertexts in.
+const archiveGroupSize = 5
+
+// WriteArchive writes the transmissions in `archive` as an intercept log
+// would have recorded them, without any of the ground truth: for each, the
+// time, the sending and receiving stations, the indicator, and the
+// ciphertext in groups of 5.
+func WriteArchive(w io.Writer, archive []Transmission) error {
+	for _, t := range archive {
+		var groups []string
+		for i := 0; i < len(t.Ciphertext); i += archiveGroupSize {
+			end := i + archiveGroupSize
+			if end > len(t.Ciphertext) {
+				end = len(t.Ciphertext)
+			}
+			groups = append(groups, t.Ciphertext[i:end])
+		}
+		positions := len(t.Indicator) / 2
+		_, err := fmt.Fprintf(w, "%v %v -> %v: %v %v\n%v\n\n",
+			t.Received.Format("2006-01-02 15:04"), t.From, t.To,
+			t.Indicator[:positions], t.Indicator[positions:], strings.Join(groups, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truthMessage is the JSON form of a message's ground truth.
+type truthMessage struct {
+	Sent       time.Time `json:"sent"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	MessageKey string    `json:"messageKey"`
+	Plaintext  string    `json:"plaintext"`
+	Decrypted  string    `json:"decrypted"`
+}
+
+// groundTruth is the JSON form of a simulation's key sheet and messages.
+type groundTruth struct {
+	keySheetJSON
+	Messages []truthMessage `json:"messages"`
+}
+
+// WriteGroundTruth writes the key sheet and the messages behind `archive`,
+// in JSON, to check solutions against. The result is also a key sheet file
+// (see ReadKeySheet).
+func WriteGroundTruth(w io.Writer, key KeySheet, archive []Transmission) error {
+	truth := groundTruth{keySheetJSON: toKeySheetJSON(key)}
+	truth.Messages = make([]truthMessage, len(archive))
+	for i, t := range archive {
+		m := &truth.Messages[i]
+		m.Sent, m.From, m.To = t.Sent, t.From, t.To
+		m.MessageKey, m.Plaintext, m.Decrypted = t.MessageKey, t.Plaintext, t.Decrypted
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(truth)
+}
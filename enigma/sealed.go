@@ -0,0 +1,94 @@
+package enigma
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// Sealed files keep their contents, such as the key sheets and ground truth
+// of a classroom exercise, unreadable to anyone without the passphrase. They
+// start with sealedHeader, followed by a random salt and nonce, and the
+// contents encrypted with AES-256-GCM, under a key derived from the
+// passphrase with PBKDF2. crypto/pbkdf2 is why this package needs Go 1.24 or
+// later.
+const sealedHeader = "ENIGMA SEALED 1\n"
+
+const (
+	sealSaltSize   = 16
+	sealIterations = 600000
+)
+
+// sealingCipher returns the cipher for `passphrase` and `salt`.
+func sealingCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, sealIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal writes `data` to `w` as a sealed file, which only Unseal with the same
+// passphrase can read.
+func Seal(w io.Writer, data []byte, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("can't seal without a passphrase")
+	}
+	salt := make([]byte, sealSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := sealingCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := append([]byte(sealedHeader), salt...)
+	sealed = append(sealed, nonce...)
+	// The header is authenticated along with the contents.
+	sealed = aead.Seal(sealed, nonce, data, []byte(sealedHeader))
+	_, err = w.Write(sealed)
+	return err
+}
+
+// IsSealed returns whether `data` is a sealed file.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealedHeader))
+}
+
+// Unseal returns the contents of the sealed file `sealed`. It returns
+// ErrWrongPassphrase if `passphrase` isn't the one it was sealed with.
+func Unseal(sealed []byte, passphrase string) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("not a sealed file")
+	}
+	rest := sealed[len(sealedHeader):]
+	if len(rest) < sealSaltSize {
+		return nil, ErrWrongPassphrase
+	}
+	aead, err := sealingCipher(passphrase, rest[:sealSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[sealSaltSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	data, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(sealedHeader))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
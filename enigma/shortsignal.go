@@ -0,0 +1,187 @@
+package enigma
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A ShortSignalBook (Kurzsignalheft) maps short code groups to stock phrases
+// such as sighting and position reports. U-boats sent such groups instead of
+// spelling reports out, to keep transmissions short enough to escape
+// direction finding. Each group is 4 letters.
+type ShortSignalBook struct {
+	meanings map[string]string
+	groups   map[string]string
+}
+
+// AddSignal records that the short signal `group` stands for `meaning`.
+func (b *ShortSignalBook) AddSignal(group, meaning string) error {
+	if b.meanings == nil {
+		b.meanings = make(map[string]string)
+		b.groups = make(map[string]string)
+	}
+	if !isShortSignal(group) {
+		return fmt.Errorf("Short signals must be 4 letters, such as 'ABCD'. Got %q", group)
+	}
+	if prev, ok := b.meanings[group]; ok {
+		return fmt.Errorf("Short signal %q already stands for %q", group, prev)
+	}
+	if prev, ok := b.groups[meaning]; ok {
+		return fmt.Errorf("%q already has short signal %q", meaning, prev)
+	}
+	b.meanings[group] = meaning
+	b.groups[meaning] = group
+	return nil
+}
+
+// Meaning returns the phrase that the short signal `group` stands for.
+func (b *ShortSignalBook) Meaning(group string) (string, error) {
+	meaning, ok := b.meanings[group]
+	if !ok {
+		return "", fmt.Errorf("short signal %q is not in the book", group)
+	}
+	return meaning, nil
+}
+
+// Signal returns the short signal for the phrase `meaning`.
+func (b *ShortSignalBook) Signal(meaning string) (string, error) {
+	group, ok := b.groups[meaning]
+	if !ok {
+		return "", fmt.Errorf("%q is not in the book", meaning)
+	}
+	return group, nil
+}
+
+func isShortSignal(s string) bool {
+	return len(s) == 4 && isTrigram(s[:3]) && isTrigram(s[1:])
+}
+
+// A WeatherReport is a weather observation, as sent in the weather short
+// signal format (Wetterkurzschlüssel) by U-boats and weather ships. Their
+// reports were a favourite source of cribs at Bletchley Park.
+type WeatherReport struct {
+	// The naval grid square of the observation, two letters and four digits,
+	// such as "AJ9863".
+	Grid string
+	// The hour of the observation, 0-23.
+	Hour int
+	// The air pressure in millibars, 950-1049.
+	Pressure int
+	// The temperature in degrees Celsius, -50 to 49.
+	Temperature int
+	// The wind direction in tens of degrees (0-35), and the wind force on the
+	// Beaufort scale (0-12).
+	WindDirection, WindForce int
+	// The visibility and the cloud cover, each on a scale of 0-9.
+	Visibility, Cloud int
+}
+
+// weatherDigits are the letters that stand for the digits 0-9 in weather
+// short signals. These are the keys of the Enigma's top row, which carried
+// the digits on the printed-number keyboards: Q for 1 through O for 9, and P
+// for 0.
+const weatherDigits = "PQWERTZUIO"
+
+// weatherReportLength is the length of an encoded WeatherReport: the grid
+// square's two letters, and 16 digits.
+const weatherReportLength = 18
+
+// EncodeWeatherReport encodes `r` as a weather short signal: the grid
+// square's letters, followed by the grid square's digits, the hour, the
+// pressure (without its hundreds), the temperature (plus 50), the wind
+// direction and force, the visibility and the cloud cover, each digit written
+// as a letter of the top row. The result is 18 letters, ready to be
+// encrypted.
+func EncodeWeatherReport(r WeatherReport) (string, error) {
+	if err := checkGridSquare(r.Grid); err != nil {
+		return "", err
+	}
+	for _, field := range []struct {
+		name          string
+		value, lo, hi int
+	}{
+		{"hour", r.Hour, 0, 23},
+		{"pressure", r.Pressure, 950, 1049},
+		{"temperature", r.Temperature, -50, 49},
+		{"wind direction", r.WindDirection, 0, 35},
+		{"wind force", r.WindForce, 0, 12},
+		{"visibility", r.Visibility, 0, 9},
+		{"cloud cover", r.Cloud, 0, 9},
+	} {
+		if field.value < field.lo || field.value > field.hi {
+			return "", fmt.Errorf("%v must be %v to %v. Got %v", field.name, field.lo, field.hi, field.value)
+		}
+	}
+	digits := fmt.Sprintf("%v%02d%02d%02d%02d%02d%d%d", r.Grid[2:], r.Hour, r.Pressure%100,
+		r.Temperature+50, r.WindDirection, r.WindForce, r.Visibility, r.Cloud)
+	var report strings.Builder
+	report.WriteString(r.Grid[:2])
+	for i := 0; i < len(digits); i++ {
+		report.WriteByte(weatherDigits[digits[i]-'0'])
+	}
+	return report.String(), nil
+}
+
+// DecodeWeatherReport reverses EncodeWeatherReport. Spaces, as left by
+// grouping, are ignored.
+func DecodeWeatherReport(s string) (WeatherReport, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s) != weatherReportLength || !isBigram(s[:2]) {
+		return WeatherReport{}, fmt.Errorf(
+			"weather short signals must be %v letters. Got %q", weatherReportLength, s)
+	}
+	digits := make([]byte, 0, weatherReportLength-2)
+	for i := 2; i < len(s); i++ {
+		d := strings.IndexByte(weatherDigits, s[i])
+		if d < 0 {
+			return WeatherReport{}, fmt.Errorf("%q at position %v does not stand for a digit", s[i], i+1)
+		}
+		digits = append(digits, byte(d)+'0')
+	}
+	number := func(from, to int) int {
+		n := 0
+		for _, d := range digits[from:to] {
+			n = n*10 + int(d-'0')
+		}
+		return n
+	}
+	r := WeatherReport{
+		Grid:          s[:2] + string(digits[:4]),
+		Hour:          number(4, 6),
+		Pressure:      number(6, 8),
+		Temperature:   number(8, 10) - 50,
+		WindDirection: number(10, 12),
+		WindForce:     number(12, 14),
+		Visibility:    number(14, 15),
+		Cloud:         number(15, 16),
+	}
+	// Pressures from 950 to 1049 are sent without their hundreds.
+	if r.Pressure >= 50 {
+		r.Pressure += 900
+	} else {
+		r.Pressure += 1000
+	}
+	// Check the ranges of the fields that can overflow them.
+	if _, err := EncodeWeatherReport(r); err != nil {
+		return WeatherReport{}, err
+	}
+	return r, nil
+}
+
+// checkGridSquare returns an error if `grid` isn't a naval grid square of two
+// letters and four digits.
+func checkGridSquare(grid string) error {
+	if len(grid) != 6 || !isBigram(grid[:2]) || !isNumber(grid[2:]) {
+		return fmt.Errorf("grid squares must be 2 letters and 4 digits, such as 'AJ9863'. Got %q", grid)
+	}
+	return nil
+}
+
+func isNumber(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
@@ -0,0 +1,346 @@
+package enigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A State is everything about an Enigma that its operator could set: which
+// reflector and rotors are installed, the ring settings, the plugboard, and
+// where the rotors (and a settable reflector) are turned to. It can be
+// encoded as JSON, to checkpoint a machine and resume it later, or to
+// exchange its configuration between tools. Plug pairs are written like
+// "AB". Settings and positions are written in the Enigma's keys, left to
+// right. For example:
+//
+//	{"reflector": "B", "rotors": ["I", "II", {"wiring": "BDFHJLCPRTXVZNYEIWGAKMUSQO", "notches": "B"}],
+//	 "ringSettings": "AAA", "plugPairs": ["AB"], "positions": "QEV"}
+//
+// The entry wheel and stepping mechanism come with the model, and are not
+// part of the state.
+type State struct {
+	Reflector         Component   `json:"reflector"`
+	Rotors            []Component `json:"rotors"`
+	RingSettings      string      `json:"ringSettings"`
+	PlugPairs         []string    `json:"plugPairs,omitempty"`
+	Positions         string      `json:"positions"`
+	ReflectorPosition string      `json:"reflectorPosition,omitempty"`
+}
+
+// A Component is a rotor or reflector in a State: either one of Rotors or
+// Reflectors, by name, or a custom one that isn't registered, by its wiring.
+// In JSON, a named component is just its name, and a custom one is an object
+// with the fields below.
+type Component struct {
+	Name string `json:"-"`
+
+	// For custom components: the wiring and a rotor's turnover points,
+	// written as for MakeAlphabetRotor, in the component's alphabet, which
+	// defaults to Letters. Thin components only fit an M4.
+	Wiring   string   `json:"wiring"`
+	Notches  string   `json:"notches,omitempty"`
+	Alphabet Alphabet `json:"alphabet,omitempty"`
+	Thin     bool     `json:"thin,omitempty"`
+}
+
+// customComponent is the JSON form of a custom Component.
+type customComponent Component
+
+// MarshalJSON implements json.Marshaler.
+func (c Component) MarshalJSON() ([]byte, error) {
+	if c.Wiring == "" {
+		return json.Marshal(c.Name)
+	}
+	return json.Marshal(customComponent(c))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Component) UnmarshalJSON(data []byte) error {
+	*c = Component{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &c.Name)
+	}
+	return json.Unmarshal(data, (*customComponent)(c))
+}
+
+// rotorComponent describes `rotor` as a Component: by its name in Rotors, if
+// it has one, or else by its wiring. Rotors that are wired the same are
+// interchangeable, so any of their names does.
+func rotorComponent(rotor Rotor) (Component, error) {
+	// Only WiredRotors can be compared, or have their wiring written down.
+	wired, ok := rotor.(WiredRotor)
+	if !ok {
+		return Component{}, fmt.Errorf("%w: only wired rotors can be saved, got a %T", ErrInvalidRotor, rotor)
+	}
+	for _, name := range RotorNames() {
+		if r, ok := Rotors[name].(WiredRotor); ok && r == wired {
+			return Component{Name: name}, nil
+		}
+	}
+	c := Component{Thin: wired.thin}
+	if wired.alphabet != Letters {
+		c.Alphabet = wired.alphabet
+	}
+	wiring := make([]byte, len(wired.alphabet))
+	for i := range wiring {
+		wiring[i] = wired.alphabet[wired.rlMapping[i]]
+		if wired.turnoverPoints[i] {
+			c.Notches += string(wired.alphabet[i])
+		}
+	}
+	c.Wiring = string(wiring)
+	return c, nil
+}
+
+// reflectorComponent describes `reflector` as a Component, like
+// rotorComponent.
+func reflectorComponent(reflector Reflector) Component {
+	for _, name := range ReflectorNames() {
+		if Reflectors[name] == reflector {
+			return Component{Name: name}
+		}
+	}
+	c := Component{Thin: reflector.thin}
+	if reflector.alphabet != Letters {
+		c.Alphabet = reflector.alphabet
+	}
+	wiring := make([]byte, len(reflector.alphabet))
+	for i := range wiring {
+		wiring[i] = reflector.alphabet[reflector.mapping[i]]
+	}
+	c.Wiring = string(wiring)
+	return c
+}
+
+// alphabet returns the alphabet of a custom component, or an error if it
+// came from JSON and can't label a component's contacts.
+func (c Component) alphabet() (Alphabet, error) {
+	if c.Alphabet == "" {
+		return Letters, nil
+	}
+	return c.Alphabet, c.Alphabet.check()
+}
+
+// rotor returns the rotor that `c` describes.
+func (c Component) rotor() (Rotor, error) {
+	if c.Wiring == "" {
+		rotor, ok := Rotors[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: rotor %v does not exist; options are %v", ErrInvalidRotor, c.Name, RotorNames())
+		}
+		return rotor, nil
+	}
+	alphabet, err := c.alphabet()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRotor, err)
+	}
+	rotor, err := makeRotor(alphabet, c.Wiring, []byte(c.Notches))
+	if err != nil {
+		return nil, err
+	}
+	rotor.thin = c.Thin
+	return *rotor, nil
+}
+
+// reflector returns the reflector that `c` describes.
+func (c Component) reflector() (Reflector, error) {
+	if c.Wiring == "" {
+		reflector, ok := Reflectors[c.Name]
+		if !ok {
+			return Reflector{}, fmt.Errorf("%w: reflector %v does not exist; options are %v",
+				ErrInvalidReflector, c.Name, ReflectorNames())
+		}
+		return reflector, nil
+	}
+	alphabet, err := c.alphabet()
+	if err != nil {
+		return Reflector{}, fmt.Errorf("%w: %s", ErrInvalidReflector, err)
+	}
+	reflector, err := makeReflector(alphabet, c.Wiring)
+	if err != nil {
+		return Reflector{}, err
+	}
+	reflector.thin = c.Thin
+	return *reflector, nil
+}
+
+func (e *enigma) SaveState() (State, error) {
+	var s State
+	if e.reflector.alphabet != "" {
+		s.Reflector = reflectorComponent(e.reflector)
+	}
+	s.Rotors = make([]Component, len(e.rotor))
+	for i, r := range e.rotor {
+		c, err := rotorComponent(r.Rotor)
+		if err != nil {
+			return State{}, fmt.Errorf("rotor %v: %w", i+1, err)
+		}
+		s.Rotors[i] = c
+	}
+	s.RingSettings = string(e.RingSettings())
+	s.PlugPairs = e.PlugPairs()
+	s.Positions = string(e.RotorPositions())
+	if p := e.ReflectorPosition(); p != 0 {
+		s.ReflectorPosition = string(p)
+	}
+	return s, nil
+}
+
+func (e *enigma) LoadState(state State) error {
+	// Set up a copy, so that nothing changes if the state doesn't fit.
+	c := e.Clone().(*enigma)
+	c.reflector = Reflector{}
+	if state.Reflector != (Component{}) {
+		reflector, err := state.Reflector.reflector()
+		if err != nil {
+			return err
+		}
+		c.InstallReflector(reflector)
+	}
+	rotors := make([]Rotor, len(state.Rotors))
+	for i, component := range state.Rotors {
+		rotor, err := component.rotor()
+		if err != nil {
+			return fmt.Errorf("rotor %v: %w", i+1, err)
+		}
+		rotors[i] = rotor
+	}
+	if err := c.InstallRotors(rotors); err != nil {
+		return err
+	}
+	if err := c.SetRingSettings([]byte(state.RingSettings)); err != nil {
+		return err
+	}
+	if err := c.SetRotorPositions([]byte(state.Positions)); err != nil {
+		return err
+	}
+	plugboard, err := parsePlugPairs(state.PlugPairs)
+	if err != nil {
+		return err
+	}
+	c.SetPlugboard(plugboard)
+	c.reflectorRotation, c.startReflectorRotation = 0, 0
+	if state.ReflectorPosition != "" {
+		if len(state.ReflectorPosition) != 1 {
+			return fmt.Errorf("%w: the reflector position must be a single key, got %q",
+				ErrBadLetter, state.ReflectorPosition)
+		}
+		if err := c.SetReflectorPosition(state.ReflectorPosition[0]); err != nil {
+			return err
+		}
+	}
+	// Copies don't call the observer, but the machine itself still should.
+	c.onStep = e.onStep
+	*e = *c
+	return nil
+}
+
+func (e *enigma) MarshalJSON() ([]byte, error) {
+	state, err := e.SaveState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+func (e *enigma) UnmarshalJSON(data []byte) error {
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	return e.LoadState(state)
+}
+
+// String returns the component's name, or for a custom component, its
+// wiring.
+func (c Component) String() string {
+	if c.Wiring != "" {
+		return c.Wiring
+	}
+	return c.Name
+}
+
+// String writes the state on one line, as ParseSettings reads it.
+func (s State) String() string {
+	var b strings.Builder
+	reflector := s.Reflector.String()
+	if reflector == "" {
+		reflector = "-"
+	}
+	b.WriteString(reflector)
+	for _, r := range s.Rotors {
+		b.WriteString(" " + r.String())
+	}
+	b.WriteString(" /")
+	if strings.Trim(s.RingSettings, letters) == "" {
+		for _, r := range []byte(s.RingSettings) {
+			fmt.Fprintf(&b, " %02d", r-'A'+1)
+		}
+	} else {
+		b.WriteString(" " + s.RingSettings)
+	}
+	b.WriteString(" / " + s.Positions)
+	if len(s.PlugPairs) > 0 || s.ReflectorPosition != "" {
+		b.WriteString(" /")
+		for _, pair := range s.PlugPairs {
+			b.WriteString(" " + pair)
+		}
+	}
+	if s.ReflectorPosition != "" {
+		b.WriteString(" / " + s.ReflectorPosition)
+	}
+	return b.String()
+}
+
+// ParseSettings reads a whole key written on one line: the reflector followed
+// by the rotors, the ring settings, the rotor positions and, optionally, the
+// plug pairs and the reflector position, separated by slashes. For example:
+//
+//	B I II III / 01 01 01 / AAA / AB CD EF
+//
+// Ring settings are written as two-digit numbers from 01, as on the key
+// sheets, or as keys like the positions. A reflector of "-" means none.
+// Components are named as in Rotors and Reflectors; custom components that
+// aren't registered can only be stored as JSON.
+func ParseSettings(s string) (State, error) {
+	sections := strings.Split(s, "/")
+	if len(sections) < 3 || len(sections) > 5 {
+		return State{}, fmt.Errorf(
+			"settings must be like 'B I II III / 01 01 01 / AAA / AB CD EF', got %q", s)
+	}
+	var state State
+	components := strings.Fields(sections[0])
+	if len(components) < 2 {
+		return State{}, fmt.Errorf("settings must start with a reflector and rotors, got %q", sections[0])
+	}
+	if components[0] != "-" {
+		state.Reflector = Component{Name: components[0]}
+	}
+	for _, name := range components[1:] {
+		state.Rotors = append(state.Rotors, Component{Name: name})
+	}
+	var ringSettings []byte
+	for _, setting := range strings.Fields(sections[1]) {
+		if len(setting) == 2 && isNumber(setting) {
+			n, _ := strconv.Atoi(setting)
+			if n < 1 || n > len(letters) {
+				return State{}, fmt.Errorf("%w: ring setting %v is not from 01 to %v", ErrBadLetter, setting, len(letters))
+			}
+			ringSettings = append(ringSettings, letters[n-1])
+		} else {
+			ringSettings = append(ringSettings, setting...)
+		}
+	}
+	state.RingSettings = string(ringSettings)
+	state.Positions = strings.Join(strings.Fields(sections[2]), "")
+	if len(sections) > 3 && strings.TrimSpace(sections[3]) != "" {
+		state.PlugPairs = strings.Fields(sections[3])
+	}
+	if len(sections) > 4 {
+		state.ReflectorPosition = strings.TrimSpace(sections[4])
+	}
+	return state, nil
+}
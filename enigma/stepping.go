@@ -0,0 +1,69 @@
+package enigma
+
+// WheelState is what a SteppingMechanism can see of a rotor before a key
+// press.
+type WheelState struct {
+	// The rotor's position, numbered from 0 (e.g. 'A').
+	Position uint8
+
+	// Whether the rotor is at one of its turnover points, so that its notch
+	// engages.
+	Notched bool
+
+	// Whether this is a thin rotor (see Rotor.Thin).
+	Thin bool
+}
+
+// SteppingMechanism decides which rotors turn on each key press. Before every
+// key press, Step is called with the state of the rotors (listed
+// left-to-right), and sets the entry in `turns` of every rotor that turns one
+// position. It returns whether the reflector turns too.
+//
+// Besides LeverStepping and GearStepping, callers can implement their own
+// mechanisms for use with NewWithStepping.
+type SteppingMechanism interface {
+	Step(rotors []WheelState, turns []bool) (reflectorTurns bool)
+}
+
+// LeverStepping is the pawl-and-ratchet stepping of the military Enigmas, with
+// its double step. It is the default.
+type LeverStepping struct{}
+
+// Step implements SteppingMechanism.
+func (LeverStepping) Step(rotors []WheelState, turns []bool) bool {
+	last := len(rotors) - 1
+	for i, r := range rotors {
+		// Thin rotors never turn; there is no pawl to push them.
+		if r.Thin {
+			continue
+		}
+		// A rotor turns when any one of the following is true:
+		// - It is the rightmost rotor (which always turns).
+		turn := i == last
+		// - It is in a notched position itself, and there's a turning rotor to its
+		//   left for it to push. This condition causes the "double step" effect for
+		//   (only) the middle rotor in a 3-rotor machine, and for the second rotor
+		//   from the right in an M4.
+		turn = turn || (i > 0 && i < last && !rotors[i-1].Thin && r.Notched)
+		// - Its right neighbour is in a notched position and will push it.
+		turn = turn || rotors[i+1].Notched
+		turns[i] = turn
+	}
+	return false
+}
+
+// GearStepping is the cog-wheel stepping of the Enigma G, which works like an
+// odometer. The rightmost rotor always turns; every other rotor, and finally
+// the reflector, turns when its right neighbour turns from a notched position.
+// There is no double step.
+type GearStepping struct{}
+
+// Step implements SteppingMechanism.
+func (GearStepping) Step(rotors []WheelState, turns []bool) bool {
+	turn := true
+	for i := len(rotors) - 1; i >= 0 && turn; i-- {
+		turns[i] = true
+		turn = rotors[i].Notched
+	}
+	return turn
+}
@@ -0,0 +1,190 @@
+package enigma
+
+import "sync"
+
+// An Enigma is not safe for concurrent use: every key press turns its
+// rotors. Either give each goroutine its own machine (see Clone and Config),
+// which is the fastest, or share one through Synchronized.
+
+// A SynchronizedEnigma is an Enigma that is safe for concurrent use, as
+// every method holds a lock. See Synchronized.
+type SynchronizedEnigma struct {
+	mu sync.Mutex
+	e  Enigma
+}
+
+// Synchronized returns an Enigma that wraps `e` and is safe for concurrent
+// use. Each method is atomic, but a message typed with Type could still be
+// interleaved with other goroutines' key presses; use Do to type a whole
+// message at once. `e` must not be used directly any more.
+func Synchronized(e Enigma) *SynchronizedEnigma {
+	return &SynchronizedEnigma{e: e}
+}
+
+// Do calls `f` with the wrapped Enigma, holding the lock throughout, so that
+// e.g. setting the rotor positions and typing a message happen together.
+// `f` must not keep the Enigma, or call methods of the SynchronizedEnigma.
+func (s *SynchronizedEnigma) Do(f func(e Enigma)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.e)
+}
+
+// InstallReflector implements Enigma.
+func (s *SynchronizedEnigma) InstallReflector(reflector Reflector) {
+	s.Do(func(e Enigma) { e.InstallReflector(reflector) })
+}
+
+// checkKey implements keyChecker.
+func (s *SynchronizedEnigma) checkKey(k byte) (key byte, err error) {
+	s.Do(func(e Enigma) { key, err = checkKey(e, k) })
+	return key, err
+}
+
+// lookUpKey implements keyChecker.
+func (s *SynchronizedEnigma) lookUpKey(k byte) (key byte, ok bool, err error) {
+	s.Do(func(e Enigma) { key, ok, err = lookUpKey(e, k) })
+	return key, ok, err
+}
+
+// InstallEntryWheel implements Enigma.
+func (s *SynchronizedEnigma) InstallEntryWheel(entryWheel EntryWheel) (err error) {
+	s.Do(func(e Enigma) { err = e.InstallEntryWheel(entryWheel) })
+	return err
+}
+
+// InstallRotors implements Enigma.
+func (s *SynchronizedEnigma) InstallRotors(rotors []Rotor) (err error) {
+	s.Do(func(e Enigma) { err = e.InstallRotors(rotors) })
+	return err
+}
+
+// ReplaceRotor implements Enigma.
+func (s *SynchronizedEnigma) ReplaceRotor(slot int, rotor Rotor) (err error) {
+	s.Do(func(e Enigma) { err = e.ReplaceRotor(slot, rotor) })
+	return err
+}
+
+// SetRingSettings implements Enigma.
+func (s *SynchronizedEnigma) SetRingSettings(settings []byte) (err error) {
+	s.Do(func(e Enigma) { err = e.SetRingSettings(settings) })
+	return err
+}
+
+// SetRotorPositions implements Enigma.
+func (s *SynchronizedEnigma) SetRotorPositions(positions []byte) (err error) {
+	s.Do(func(e Enigma) { err = e.SetRotorPositions(positions) })
+	return err
+}
+
+// Reset implements Enigma.
+func (s *SynchronizedEnigma) Reset() {
+	s.Do(func(e Enigma) { e.Reset() })
+}
+
+// SaveState implements Enigma.
+func (s *SynchronizedEnigma) SaveState() (state State, err error) {
+	s.Do(func(e Enigma) { state, err = e.SaveState() })
+	return state, err
+}
+
+// LoadState implements Enigma.
+func (s *SynchronizedEnigma) LoadState(state State) (err error) {
+	s.Do(func(e Enigma) { err = e.LoadState(state) })
+	return err
+}
+
+// MarshalJSON implements Enigma.
+func (s *SynchronizedEnigma) MarshalJSON() (data []byte, err error) {
+	s.Do(func(e Enigma) { data, err = e.MarshalJSON() })
+	return data, err
+}
+
+// UnmarshalJSON implements Enigma.
+func (s *SynchronizedEnigma) UnmarshalJSON(data []byte) (err error) {
+	s.Do(func(e Enigma) { err = e.UnmarshalJSON(data) })
+	return err
+}
+
+// RotorPositions implements Enigma.
+func (s *SynchronizedEnigma) RotorPositions() (positions []byte) {
+	s.Do(func(e Enigma) { positions = e.RotorPositions() })
+	return positions
+}
+
+// ReflectorPosition implements Enigma.
+func (s *SynchronizedEnigma) ReflectorPosition() (position byte) {
+	s.Do(func(e Enigma) { position = e.ReflectorPosition() })
+	return position
+}
+
+// Reflector implements Enigma.
+func (s *SynchronizedEnigma) Reflector() (reflector Reflector) {
+	s.Do(func(e Enigma) { reflector = e.Reflector() })
+	return reflector
+}
+
+// Rotors implements Enigma.
+func (s *SynchronizedEnigma) Rotors() (rotors []Rotor) {
+	s.Do(func(e Enigma) { rotors = e.Rotors() })
+	return rotors
+}
+
+// RingSettings implements Enigma.
+func (s *SynchronizedEnigma) RingSettings() (settings []byte) {
+	s.Do(func(e Enigma) { settings = e.RingSettings() })
+	return settings
+}
+
+// PlugPairs implements Enigma.
+func (s *SynchronizedEnigma) PlugPairs() (pairs []string) {
+	s.Do(func(e Enigma) { pairs = e.PlugPairs() })
+	return pairs
+}
+
+// SetPlugboard implements Enigma.
+func (s *SynchronizedEnigma) SetPlugboard(plugboard Plugboard) {
+	s.Do(func(e Enigma) { e.SetPlugboard(plugboard) })
+}
+
+// SetReflectorPosition implements Enigma.
+func (s *SynchronizedEnigma) SetReflectorPosition(position byte) (err error) {
+	s.Do(func(e Enigma) { err = e.SetReflectorPosition(position) })
+	return err
+}
+
+// KeyPress implements Enigma.
+func (s *SynchronizedEnigma) KeyPress(k byte) (lamp byte) {
+	s.Do(func(e Enigma) { lamp = e.KeyPress(k) })
+	return lamp
+}
+
+// TryKeyPress implements Enigma.
+func (s *SynchronizedEnigma) TryKeyPress(k byte) (lamp byte, err error) {
+	s.Do(func(e Enigma) { lamp, err = e.TryKeyPress(k) })
+	return lamp, err
+}
+
+// TraceKeyPress implements Enigma.
+func (s *SynchronizedEnigma) TraceKeyPress(k byte, trace *Trace) (lamp byte) {
+	s.Do(func(e Enigma) { lamp = e.TraceKeyPress(k, trace) })
+	return lamp
+}
+
+// OnStep implements Enigma. The observer is called with the lock held, so
+// it must not call the SynchronizedEnigma's methods.
+func (s *SynchronizedEnigma) OnStep(observer func(positions []byte)) {
+	s.Do(func(e Enigma) { e.OnStep(observer) })
+}
+
+// Clone implements Enigma. The copy is synchronized too.
+func (s *SynchronizedEnigma) Clone() (clone Enigma) {
+	s.Do(func(e Enigma) { clone = Synchronized(e.Clone()) })
+	return clone
+}
+
+// CloneState implements Enigma. The copy is synchronized too.
+func (s *SynchronizedEnigma) CloneState() (clone Enigma) {
+	s.Do(func(e Enigma) { clone = Synchronized(e.CloneState()) })
+	return clone
+}
@@ -0,0 +1,19 @@
+package enigma
+
+// NewT creates a new Enigma T ("Tirpitz"), built for communication between the
+// German and Japanese navies. It steps like the Enigma I, but has:
+//   - Five notches on each of its rotors.
+//   - A reflector that can be set to a position (see SetReflectorPosition),
+//     which stays put during operation.
+//   - An entry wheel with a wiring of its own.
+//   - No plugboard.
+//
+// Its rotors are "T-I" through "T-VIII" in Rotors, and its reflector is "T" in
+// Reflectors.
+func NewT() Enigma {
+	return &enigma{
+		alphabet:          letters,
+		settableReflector: true,
+		entry:             &tirpitzEntryWheel,
+	}
+}
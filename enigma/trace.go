@@ -0,0 +1,139 @@
+package enigma
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The stages of the signal path that a TraceStep can record.
+const (
+	PlugboardStage  = "plugboard"
+	EntryWheelStage = "entry wheel"
+	RotorStage      = "rotor"
+	ReflectorStage  = "reflector"
+)
+
+// A TraceStep is the signal after one stage of its path through an Enigma.
+type TraceStep struct {
+	// The component the signal just passed through: one of the stages above.
+	Stage string
+
+	// For the RotorStage, the rotor's slot, counting from 0 on the left.
+	Slot int
+
+	// The signal, as the key whose contact it is on. Between the rotors, this
+	// is the contact on the stationary chassis, as if every rotor were at 'A'
+	// with ring setting 'A'.
+	Signal byte
+}
+
+// A Trace records the path of one key press through an Enigma, for teaching
+// and debugging. A Trace can be reused for any number of key presses, and
+// only allocates when it needs more room than it had, so tracing every key
+// press costs no allocations after the first.
+type Trace struct {
+	// The key that was pressed, and the lamp that lit up.
+	Key, Lamp byte
+
+	// The rotor positions as the signal passed through, after the rotors
+	// turned for the key press, and the reflector position for models where
+	// it can be set, or 0 (see Enigma.ReflectorPosition).
+	Positions         []byte
+	ReflectorPosition byte
+
+	// The signal after each stage, in the order it passed through them: the
+	// plugboard, the entry wheel, the rotors right to left, the reflector,
+	// the rotors left to right, the entry wheel and the plugboard.
+	Steps []TraceStep
+}
+
+// NewTrace returns a Trace with room for a key press on a machine with
+// `rotors` rotors, so that tracing never allocates.
+func NewTrace(rotors int) *Trace {
+	return &Trace{
+		Positions: make([]byte, 0, rotors),
+		Steps:     make([]TraceStep, 0, 2*rotors+5),
+	}
+}
+
+// record adds a step to the trace, if there is one.
+func (t *Trace) record(stage string, slot int, signal byte) {
+	if t != nil {
+		t.Steps = append(t.Steps, TraceStep{Stage: stage, Slot: slot, Signal: signal})
+	}
+}
+
+// traceJSON is how a Trace is written as JSON, with keys and signals as
+// strings rather than numbers.
+type traceJSON struct {
+	Key               string          `json:"key"`
+	Lamp              string          `json:"lamp"`
+	Positions         string          `json:"positions"`
+	ReflectorPosition string          `json:"reflectorPosition,omitempty"`
+	Steps             []traceStepJSON `json:"steps"`
+}
+
+// traceStepJSON is how a TraceStep is written as JSON. Only rotor steps have
+// a slot.
+type traceStepJSON struct {
+	Stage  string `json:"stage"`
+	Slot   *int   `json:"slot,omitempty"`
+	Signal string `json:"signal"`
+}
+
+// MarshalJSON implements json.Marshaler, writing keys, signals and positions
+// as strings, e.g. {"key": "A", "lamp": "B", "positions": "AAB", "steps":
+// [{"stage": "plugboard", "signal": "A"}, {"stage": "rotor", "slot": 2, ...}]}.
+func (t Trace) MarshalJSON() ([]byte, error) {
+	j := traceJSON{
+		Key: string(t.Key), Lamp: string(t.Lamp), Positions: string(t.Positions),
+		Steps: make([]traceStepJSON, len(t.Steps)),
+	}
+	if t.ReflectorPosition != 0 {
+		j.ReflectorPosition = string(t.ReflectorPosition)
+	}
+	for i, step := range t.Steps {
+		j.Steps[i] = traceStepJSON{Stage: step.Stage, Signal: string(step.Signal)}
+		if step.Stage == RotorStage {
+			slot := step.Slot
+			j.Steps[i].Slot = &slot
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading what MarshalJSON writes.
+func (t *Trace) UnmarshalJSON(data []byte) error {
+	var j traceJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if len(j.Key) != 1 || len(j.Lamp) != 1 {
+		return fmt.Errorf("%w: a trace's key and lamp must be single keys, got %q and %q",
+			ErrBadLetter, j.Key, j.Lamp)
+	}
+	steps := make([]TraceStep, len(j.Steps))
+	for i, step := range j.Steps {
+		if len(step.Signal) != 1 {
+			return fmt.Errorf("%w: step %v of the trace has signal %q", ErrBadLetter, i+1, step.Signal)
+		}
+		steps[i] = TraceStep{Stage: step.Stage, Signal: step.Signal[0]}
+		if step.Slot != nil {
+			steps[i].Slot = *step.Slot
+		}
+	}
+	var reflectorPosition byte
+	switch len(j.ReflectorPosition) {
+	case 0:
+	case 1:
+		reflectorPosition = j.ReflectorPosition[0]
+	default:
+		return fmt.Errorf("%w: a trace's reflector position must be a single key, got %q",
+			ErrBadLetter, j.ReflectorPosition)
+	}
+	*t = Trace{
+		Key: j.Key[0], Lamp: j.Lamp[0], Positions: []byte(j.Positions),
+		ReflectorPosition: reflectorPosition, Steps: steps,
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package enigma
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TranscriptionProblem describes something suspicious that was found while
+// cleaning up a transcribed message. Problems don't stop the cleanup; it's up
+// to the human to decide whether the result can be trusted.
+type TranscriptionProblem struct {
+	// The 1-based line and column (in characters) of the problem in the
+	// original text. Both are 0 for problems with the message as a whole.
+	Line, Column int
+
+	Description string
+}
+
+func (p TranscriptionProblem) String() string {
+	if p.Line == 0 {
+		return p.Description
+	}
+	return fmt.Sprintf("line %v, column %v: %v", p.Line, p.Column, p.Description)
+}
+
+// isLineNumber returns whether `word`, found at the start of a line, looks
+// like a line number added by a transcriber (e.g. "12", "12." or "12:")
+// rather than part of the message.
+func isLineNumber(word string) bool {
+	word = strings.TrimRight(word, ".:)")
+	if word == "" {
+		return false
+	}
+	for _, c := range word {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// transcribedGroup is a single group of letters of a cleaned-up message,
+// along with the position in the original text where it started.
+type transcribedGroup struct {
+	letters      string
+	line, column int
+}
+
+// usualGroupSize returns the most common length among `groups`, which is the
+// group size the message was written in. The last group is ignored, since it
+// is usually short.
+func usualGroupSize(groups []transcribedGroup) int {
+	counts := make(map[int]int)
+	for _, g := range groups[:len(groups)-1] {
+		counts[len(g.letters)]++
+	}
+	size := len(groups[len(groups)-1].letters)
+	for length, count := range counts {
+		if count > counts[size] || (count == counts[size] && length > size) {
+			size = length
+		}
+	}
+	return size
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%v %v", n, word)
+	}
+	return fmt.Sprintf("%v %vs", n, word)
+}
+
+// checkLetterCount compares the number of letters in `groups` with the
+// `letterCount` from the message header, the way a cipher clerk would before
+// starting to decrypt. If they differ, it also points out the groups that
+// aren't of the usual size, since those are where letters were most likely
+// lost or added.
+func checkLetterCount(groups []transcribedGroup, letterCount int) []TranscriptionProblem {
+	letters := 0
+	for _, g := range groups {
+		letters += len(g.letters)
+	}
+	if letters == letterCount {
+		return nil
+	}
+	problems := []TranscriptionProblem{{
+		Description: fmt.Sprintf(
+			"message has %v letters, but its header states %v", letters, letterCount),
+	}}
+	if len(groups) == 0 {
+		return problems
+	}
+
+	size := usualGroupSize(groups)
+	explained := 0
+	for i, g := range groups {
+		diff := len(g.letters) - size
+		if diff == 0 || (diff < 0 && i == len(groups)-1) {
+			continue
+		}
+		var description string
+		if diff < 0 {
+			description = fmt.Sprintf(
+				"group %v (%v) has %v instead of %v; %v may be missing here",
+				i+1, g.letters, plural(len(g.letters), "letter"), size, plural(-diff, "letter"))
+		} else {
+			description = fmt.Sprintf(
+				"group %v (%v) has %v instead of %v; %v may be here",
+				i+1, g.letters, plural(len(g.letters), "letter"), size, plural(diff, "extra letter"))
+		}
+		problems = append(problems, TranscriptionProblem{
+			Line: g.line, Column: g.column, Description: description,
+		})
+		explained += diff
+	}
+
+	// Whatever the irregular groups don't explain is most likely a whole group
+	// that was skipped or copied twice.
+	if unexplained := letterCount - letters + explained; unexplained != 0 && unexplained%size == 0 {
+		if unexplained > 0 {
+			problems = append(problems, TranscriptionProblem{
+				Description: fmt.Sprintf("%v may be missing", plural(unexplained/size, "whole group")),
+			})
+		} else {
+			problems = append(problems, TranscriptionProblem{
+				Description: fmt.Sprintf("%v may have been copied twice", plural(-unexplained/size, "whole group")),
+			})
+		}
+	}
+	return problems
+}
+
+// CleanTranscription prepares a scanned or hand-transcribed message for the
+// Enigma. It strips line numbers, converts letters to upper case, drops every
+// character that isn't a letter, and collapses all whitespace into single
+// spaces. Everything that looks off is reported as a problem.
+//
+// Messages were sent with a header stating their letter count (the
+// "Buchstabenzahl"). If `letterCount` is positive, the cleaned message is
+// checked against it, and any discrepancy is traced back to the groups where
+// letters were most likely lost or added.
+func CleanTranscription(text string, letterCount int) (string, []TranscriptionProblem) {
+	var problems []TranscriptionProblem
+	var groups []transcribedGroup
+
+	// addWord cleans up a single whitespace-separated word, which starts at
+	// the given line and column.
+	addWord := func(word []rune, line, column int) {
+		var group strings.Builder
+		for i, c := range word {
+			upper := unicode.ToUpper(c)
+			if upper < 'A' || upper > 'Z' {
+				problems = append(problems, TranscriptionProblem{
+					Line: line, Column: column + i,
+					Description: fmt.Sprintf("dropped illegal character %q", c),
+				})
+				continue
+			}
+			group.WriteRune(upper)
+		}
+		if group.Len() > 0 {
+			groups = append(groups, transcribedGroup{group.String(), line, column})
+		}
+	}
+
+	for l, line := range strings.Split(text, "\n") {
+		chars := []rune(line)
+		firstWord := true
+		for start := 0; start < len(chars); {
+			if unicode.IsSpace(chars[start]) {
+				start++
+				continue
+			}
+			end := start
+			for end < len(chars) && !unicode.IsSpace(chars[end]) {
+				end++
+			}
+			word := chars[start:end]
+			if !firstWord || !isLineNumber(string(word)) {
+				addWord(word, l+1, start+1)
+			}
+			firstWord = false
+			start = end
+		}
+	}
+
+	if letterCount > 0 {
+		problems = append(problems, checkLetterCount(groups, letterCount)...)
+	}
+	cleaned := make([]string, len(groups))
+	for i, g := range groups {
+		cleaned[i] = g.letters
+	}
+	return strings.Join(cleaned, " "), problems
+}
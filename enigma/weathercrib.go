@@ -0,0 +1,58 @@
+package enigma
+
+import (
+	"math/rand"
+	"time"
+)
+
+// weatherTemperatures are rough mean sea-level temperatures in the North
+// Atlantic, in degrees Celsius, for each month from January.
+var weatherTemperatures = [12]int{4, 3, 4, 6, 8, 11, 13, 14, 12, 10, 7, 5}
+
+// GenerateWeatherReport returns a plausible weather report, as a U-boat in
+// the North Atlantic would have sent from grid square `grid` at time `t`.
+// Temperatures follow the season, pressures cluster around 1010 millibars and
+// winds mostly blow from the west.
+func GenerateWeatherReport(rnd *rand.Rand, t time.Time, grid string) (WeatherReport, error) {
+	if err := checkGridSquare(grid); err != nil {
+		return WeatherReport{}, err
+	}
+	return WeatherReport{
+		Grid:        grid,
+		Hour:        t.Hour(),
+		Pressure:    clamp(1010+int(rnd.NormFloat64()*12), 950, 1049),
+		Temperature: clamp(weatherTemperatures[t.Month()-1]+rnd.Intn(7)-3, -50, 49),
+		// Mostly south-westerly to northerly winds.
+		WindDirection: (20 + rnd.Intn(18)) % 36,
+		WindForce:     clamp(2+rnd.Intn(5)+rnd.Intn(5), 0, 12),
+		Visibility:    rnd.Intn(10),
+		Cloud:         rnd.Intn(10),
+	}, nil
+}
+
+// WeatherCrib returns the start of a weather short signal sent from grid
+// square `grid` at time `t`: the grid square and the hour. Since the
+// codebreakers knew where the weather boats were stationed and when they
+// reported, this part of their messages could be guessed, and made a crib.
+func WeatherCrib(t time.Time, grid string) (string, error) {
+	r := WeatherReport{Grid: grid, Hour: t.Hour(), Pressure: 1000}
+	s, err := EncodeWeatherReport(r)
+	if err != nil {
+		return "", err
+	}
+	return s[:weatherCribLength], nil
+}
+
+// weatherCribLength is the length of a WeatherCrib: the grid square's two
+// letters and four digits, and the hour's two.
+const weatherCribLength = 8
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
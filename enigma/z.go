@@ -0,0 +1,37 @@
+package enigma
+
+// digits are the keys of the Enigma Z, in the order of the contacts they
+// connect to.
+const digits = "1234567890"
+
+// NewZ creates a new Enigma Z, a numeric Enigma whose components have only 10
+// contacts, labeled with the digits 1-9 and 0. Its keys, lamps, ring settings
+// and rotor positions are all digits. It steps like the Enigma I, but has a
+// reflector that can be set to a position (see SetReflectorPosition), and no
+// plugboard.
+//
+// Its rotors are "Z-I" through "Z-III" in Rotors, and its reflector is "Z" in
+// Reflectors. Their wirings are those of the Z30 at the Crypto Museum; which
+// positions the notches are in is not well documented, and we assume each
+// rotor turns its neighbour on going from 9 to 0.
+func NewZ() Enigma {
+	return &enigma{
+		alphabet:          digits,
+		settableReflector: true,
+	}
+}
+
+// TypeDigits will press the digit keys in `msg` on `e`, an Enigma Z, and
+// returns the sequence of lights that result. Anything in `msg` that isn't a
+// digit, such as spaces separating groups, is passed through unchanged.
+func TypeDigits(e Enigma, msg string) string {
+	buffer := make([]byte, len(msg))
+	for i := 0; i < len(msg); i++ {
+		if msg[i] < '0' || msg[i] > '9' {
+			buffer[i] = msg[i]
+			continue
+		}
+		buffer[i] = e.KeyPress(msg[i])
+	}
+	return string(buffer)
+}
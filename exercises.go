@@ -0,0 +1,119 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// The exercise commands demonstrate and simulate the Enigma and the work of
+// breaking it. Build with the 'cipheronly' tag to leave them out.
+
+const withExercises = true
+
+// addExerciseCommands adds the exercise commands to `root`.
+func addExerciseCommands(root *cobra.Command) {
+	var cmdFrequency = &cobra.Command{
+		Use:   "frequency [message]",
+		Short: "Compare the letter frequencies of a message and its encryption",
+		Long: `Encrypts the given message and shows histograms of the letters in the plaintext and the 
+ciphertext side by side, demonstrating why counting letters doesn't break the Enigma. Use the 
+same flags as for 'crypt' to set up the machine.`,
+		Args: cobra.ArbitraryArgs,
+		Run:  frequency,
+	}
+	addMachineFlags(cmdFrequency)
+	cmdFrequency.Flags().StringVar(&svgFileFlag, "svg", "", "Also write the histograms to this SVG file")
+
+	var cmdDemo = &cobra.Command{
+		Use:   "demo",
+		Short: "Demonstrate properties of the Enigma",
+	}
+	cmdDemo.PersistentFlags().IntVar(&trialsFlag, "trials", 1000, "The number of random keys to try")
+	cmdDemo.PersistentFlags().Int64Var(&seedFlag, "seed", 0,
+		"The seed for choosing random keys, to repeat a run. Defaults to the current time")
+	cmdDemo.AddCommand(&cobra.Command{
+		Use:   "reciprocity",
+		Short: "Show that typing a ciphertext with the same key gives back the plaintext",
+		Args:  cobra.NoArgs,
+		Run:   demoReciprocity,
+	}, &cobra.Command{
+		Use:   "no-self-map",
+		Short: "Show that no letter ever encrypts to itself, and how that helps place cribs",
+		Args:  cobra.NoArgs,
+		Run:   demoNoSelfMap,
+	})
+
+	var cmdBigrams = &cobra.Command{
+		Use:   "bigrams",
+		Short: "Generate or check a naval bigram table",
+		Long: `Prints a random, complete bigram table (Doppelbuchstabentauschtafel) for practicing the 
+naval indicator procedure, in the file format the library reads: pairs like 'AB=XY', separated 
+by whitespace. With --check, checks that a table file is valid and complete instead.`,
+		Args: cobra.NoArgs,
+		Run:  bigrams,
+	}
+	cmdBigrams.Flags().Int64Var(&seedFlag, "seed", 0,
+		"The seed for generating the table, to generate it again. Defaults to the current time")
+	cmdBigrams.Flags().StringVar(&checkFileFlag, "check", "", "A bigram table file to check")
+
+	var cmdWeather = &cobra.Command{
+		Use:   "weather",
+		Short: "Generate weather short signals and their crib",
+		Long: `Prints the crib that a weather report from a given grid square and hour would start 
+with, followed by plausible reports in the weather short signal format, ready to be encrypted 
+with 'crypt'.`,
+		Args: cobra.NoArgs,
+		Run:  weather,
+	}
+	cmdWeather.Flags().StringVar(&gridFlag, "grid", "AJ9863",
+		"The naval grid square the reports are sent from: 2 letters and 4 digits")
+	cmdWeather.Flags().StringVar(&reportTimeFlag, "time", "1941-02-03T06",
+		"The date and hour of the reports, e.g. 1941-02-03T06")
+	cmdWeather.Flags().IntVar(&reportsFlag, "reports", 5, "The number of reports to generate")
+	cmdWeather.Flags().Int64Var(&seedFlag, "seed", 0,
+		"The seed for generating the reports, to generate them again. Defaults to the current time")
+
+	var cmdGrid = &cobra.Command{
+		Use:   "grid (square | latitude longitude)",
+		Short: "Convert between naval grid squares and positions",
+		Long: `Given a naval grid square such as AJ9863, prints the latitude and longitude of its 
+middle. Given a latitude and longitude in degrees (north and east positive), prints the grid 
+square they are in; put '--' before them if either is negative. The grid's layout is a regular 
+approximation of the Kriegsmarine's chart, not a copy of it.`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  grid,
+	}
+
+	var cmdSimulate = &cobra.Command{
+		Use:   "simulate scenario.json",
+		Short: "Simulate the radio traffic of a key net",
+		Long: `Runs the simulation scripted in a scenario file: stations sharing a key sheet send each 
+other messages on a schedule, with operator errors and garbles on the air. Prints the traffic 
+as an intercept archive, for cracking exercises; --truth saves the keys and plaintexts behind it. 
+See the README for the scenario file format.`,
+		Args: cobra.ExactArgs(1),
+		Run:  simulate,
+	}
+	cmdSimulate.Flags().StringVar(&truthFileFlag, "truth", "",
+		"Also write the key sheet, message keys and plaintexts to this JSON file")
+	cmdSimulate.Flags().StringVar(&passphraseFileFlag, "passphraseFile", "",
+		"Seal the --truth file with the passphrase in this file")
+
+	var cmdCrib = &cobra.Command{
+		Use:   "crib ciphertext",
+		Short: "Slide a crib along a ciphertext, and make a menu of it",
+		Long: `Lines up the crib in --crib with the ciphertext at every offset, marking the letters that 
+would encrypt to themselves, which rules the offset out. With --offset, prints the menu of the 
+crib at that offset instead: the letter pairs the machine connected at each position, as the 
+codebreakers wired into the Bombe. Offsets and positions count from 0.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  crib,
+	}
+	cmdCrib.Flags().StringVar(&cribFlag, "crib", "", "The crib, i.e. the plaintext guessed to be in the message")
+	cmdCrib.Flags().IntVar(&offsetFlag, "offset", -1, "Print the menu of the crib at this offset")
+	cmdCrib.Flags().BoolVar(&menuDOTFlag, "dot", false,
+		"With --offset, print the menu as a Graphviz DOT graph instead, e.g. to render with 'dot -Tsvg'")
+
+	root.AddCommand(cmdFrequency, cmdDemo, cmdBigrams, cmdWeather, cmdGrid, cmdSimulate, cmdCrib)
+}
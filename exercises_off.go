@@ -0,0 +1,26 @@
+//go:build cipheronly
+
+package main
+
+import (
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+)
+
+const withExercises = false
+
+// addExerciseCommands adds hidden stand-ins for the exercise commands in a
+// cipher-only build, which explain why they are missing.
+func addExerciseCommands(root *cobra.Command) {
+	for _, name := range exerciseCommands {
+		root.AddCommand(&cobra.Command{
+			Use:                name,
+			Hidden:             true,
+			DisableFlagParsing: true,
+			Run: func(cmd *cobra.Command, args []string) {
+				glog.Fatalf("'%v' is not part of this binary, which was built with -tags=cipheronly. "+
+					"Build without that tag to use it; see 'enigma about --features'", cmd.Use)
+			},
+		})
+	}
+}
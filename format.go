@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var groupSizeFlag int
+var groupSeparatorFlag string
+var groupsPerLineFlag int
+var continuationFlag string
+
+// outputFormat describes how to lay out a message's letters, since archives
+// differ in how they transcribed messages.
+type outputFormat struct {
+	// The number of letters per group. If 0, the message keeps its grouping.
+	groupSize int
+
+	// What to put between groups on the same line.
+	separator string
+
+	// The number of groups per line. If 0, the message is a single line.
+	groupsPerLine int
+
+	// A marker at the end of every line that the message continues after.
+	continuation string
+}
+
+// parseSeparator turns the name of a group separator ("space" or "newline")
+// into the separator itself. Anything else is used as it is.
+func parseSeparator(name string) string {
+	switch name {
+	case "space":
+		return " "
+	case "newline":
+		return "\n"
+	}
+	return name
+}
+
+// newOutputFormat returns the output format set by the formatting flags (see
+// addFormatFlags).
+func newOutputFormat() (outputFormat, error) {
+	if groupSizeFlag < 0 || groupsPerLineFlag < 0 {
+		return outputFormat{}, fmt.Errorf(
+			"Group size and groups per line can't be negative. Got %v and %v", groupSizeFlag, groupsPerLineFlag)
+	}
+	return outputFormat{
+		groupSize:     groupSizeFlag,
+		separator:     parseSeparator(groupSeparatorFlag),
+		groupsPerLine: groupsPerLineFlag,
+		continuation:  continuationFlag,
+	}, nil
+}
+
+// format lays out `text`, whose groups are separated by whitespace.
+func (f outputFormat) format(text string) string {
+	groups := strings.Fields(text)
+	if f.groupSize > 0 {
+		letters := strings.Join(groups, "")
+		groups = nil
+		for len(letters) > f.groupSize {
+			groups = append(groups, letters[:f.groupSize])
+			letters = letters[f.groupSize:]
+		}
+		if letters != "" {
+			groups = append(groups, letters)
+		}
+	}
+	if f.groupsPerLine == 0 {
+		return strings.Join(groups, f.separator)
+	}
+	var lines []string
+	for len(groups) > f.groupsPerLine {
+		lines = append(lines, strings.Join(groups[:f.groupsPerLine], f.separator)+f.continuation)
+		groups = groups[f.groupsPerLine:]
+	}
+	return strings.Join(append(lines, strings.Join(groups, f.separator)), "\n")
+}
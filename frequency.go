@@ -0,0 +1,124 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var svgFileFlag string
+
+// The widest bar in the terminal histogram, in characters.
+const maxBarWidth = 30
+
+// letterCounts returns how often each letter A-Z occurs in `text`.
+func letterCounts(text string) [26]int {
+	var counts [26]int
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 'A' && text[i] <= 'Z' {
+			counts[text[i]-'A']++
+		}
+	}
+	return counts
+}
+
+// mostCommon returns the most common letter in `counts`, and its count.
+func mostCommon(counts [26]int) (byte, int) {
+	best := 0
+	for i, count := range counts {
+		if count > counts[best] {
+			best = i
+		}
+	}
+	return byte(best) + 'A', counts[best]
+}
+
+// bar returns a bar of '#' characters for `count`, scaled so that `max` gets
+// the widest bar.
+func bar(count, max int) string {
+	if max == 0 {
+		return ""
+	}
+	return strings.Repeat("#", count*maxBarWidth/max)
+}
+
+// writeFrequencySVG writes a bar chart of the plain and cipher letter counts,
+// side by side for every letter, to `path`.
+func writeFrequencySVG(path string, plain, cipher [26]int, max int) error {
+	const barWidth, chartHeight, margin = 10, 200, 20
+	width := 26*3*barWidth + 2*margin
+	height := chartHeight + 3*margin
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v">`+"\n", width, height)
+	fmt.Fprintf(&svg, `<text x="%v" y="%v" fill="steelblue">plaintext</text>`+"\n", margin, margin)
+	fmt.Fprintf(&svg, `<text x="%v" y="%v" fill="firebrick">ciphertext</text>`+"\n", margin+100, margin)
+	for i := 0; i < 26; i++ {
+		x := margin + i*3*barWidth
+		for j, series := range []struct {
+			count int
+			color string
+		}{{plain[i], "steelblue"}, {cipher[i], "firebrick"}} {
+			h := 0
+			if max > 0 {
+				h = series.count * chartHeight / max
+			}
+			fmt.Fprintf(&svg, `<rect x="%v" y="%v" width="%v" height="%v" fill="%v"/>`+"\n",
+				x+j*barWidth, 2*margin+chartHeight-h, barWidth, h, series.color)
+		}
+		fmt.Fprintf(&svg, `<text x="%v" y="%v" text-anchor="middle">%c</text>`+"\n",
+			x+barWidth, height-margin/2, 'A'+i)
+	}
+	svg.WriteString("</svg>\n")
+	return ioutil.WriteFile(path, []byte(svg.String()), 0644)
+}
+
+func frequency(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	e := setUpEnigma(cmd)
+
+	plain, _ := enigma.CleanTranscription(strings.Join(args, " "), 0)
+	plain = strings.Replace(plain, " ", "", -1)
+	if plain == "" {
+		glog.Fatalf("Got no message to type")
+	}
+	cipher := enigma.Type(e, plain)
+	plainCounts, cipherCounts := letterCounts(plain), letterCounts(cipher)
+
+	// Both histograms use the same scale, so they can be compared directly.
+	_, plainMax := mostCommon(plainCounts)
+	_, cipherMax := mostCommon(cipherCounts)
+	max := plainMax
+	if cipherMax > max {
+		max = cipherMax
+	}
+	fmt.Printf("     %-*v   %v\n", maxBarWidth+5, "plaintext", "ciphertext")
+	for i := 0; i < 26; i++ {
+		line := fmt.Sprintf("%c  %4d %-*v %4d %v", 'A'+i,
+			plainCounts[i], maxBarWidth, bar(plainCounts[i], max),
+			cipherCounts[i], bar(cipherCounts[i], max))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	plainLetter, _ := mostCommon(plainCounts)
+	cipherLetter, _ := mostCommon(cipherCounts)
+	fmt.Printf(`
+The most common plaintext letter is %c (%.0f%%); the most common ciphertext letter is %c (%.0f%%).
+A simple substitution cipher would keep the plaintext's peaks, just under different letters. The
+Enigma steps its rotors with every key press, so each letter is enciphered with a different
+alphabet, and the ciphertext frequencies come out nearly flat: counting letters reveals nothing.
+`, plainLetter, 100*float64(plainMax)/float64(len(plain)),
+		cipherLetter, 100*float64(cipherMax)/float64(len(cipher)))
+
+	if svgFileFlag != "" {
+		if err := writeFrequencySVG(svgFileFlag, plainCounts, cipherCounts, max); err != nil {
+			glog.Fatalf("Could not write %v: %s", svgFileFlag, err)
+		}
+	}
+}
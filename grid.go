@@ -0,0 +1,41 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+func grid(cmd *cobra.Command, args []string) {
+	setUpLogging()
+
+	// A grid square to look up.
+	if len(args) == 1 {
+		lat, lon, err := enigma.GridSquareCenter(args[0])
+		if err != nil {
+			glog.Fatalf("%s", err)
+		}
+		fmt.Printf("%v is centered on %.2f, %.2f\n", args[0], lat, lon)
+		return
+	}
+
+	// A position to find the grid square of.
+	lat, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		glog.Fatalf("Got invalid latitude %q: %s", args[0], err)
+	}
+	lon, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		glog.Fatalf("Got invalid longitude %q: %s", args[1], err)
+	}
+	square, err := enigma.GridSquare(lat, lon)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	fmt.Printf("%.2f, %.2f is in grid square %v\n", lat, lon, square)
+}
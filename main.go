@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
-	"strconv"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	goflag "flag"
 
@@ -13,118 +16,401 @@ import (
 
 var debugFlag bool
 
+var modelFlag string
 var reflectorFlag string
 var rotorsFlag []string
 var ringSettingsFlag []string
 var plugPairsFlag []string
 var rotorPositionsFlag []string
+var reflectorPositionFlag string
+var settingsFlag string
+var settingsFileFlag string
+var profileNameFlag string
 
-func crypt(cmd *cobra.Command, args []string) {
+// modelFromFile and settingsFromFile record that --model and --settings were
+// taken from --settingsFile.
+var modelFromFile, settingsFromFile bool
+var strictHistoryFlag string
+var componentFileFlag string
+var customRotorsFlag []string
+var customReflectorsFlag []string
+var copyFlag bool
+var pasteFlag bool
+var cleanFlag bool
+var letterCountFlag int
+var substituteFlag string
+var spellFlag bool
+var spelledFlag bool
+var maxLengthFlag int
+var lengthPolicyFlag string
+
+// setUpLogging configures glog according to the command-line flags.
+func setUpLogging() {
 	if debugFlag {
 		goflag.Set("alsologtostderr", "true")
 	}
 	goflag.Parse()
+}
 
-	e := enigma.New()
+// loadComponentFile registers the custom components in --componentFile, if
+// given.
+func loadComponentFile() {
+	if componentFileFlag == "" {
+		return
+	}
+	f, err := os.Open(componentFileFlag)
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", componentFileFlag, err)
+	}
+	defer f.Close()
+	if err := enigma.LoadComponents(f); err != nil {
+		glog.Fatalf("%v: %s", componentFileFlag, err)
+	}
+	glog.Infof("Loaded components from %v", componentFileFlag)
+}
 
-	// Install the reflector.
-	{
-		r, ok := enigma.Reflectors[reflectorFlag]
-		if !ok {
-			glog.Fatalf(
-				"Reflector '%v' does not exist; options are %v",
-				reflectorFlag, enigma.ReflectorNames())
+// registerCustomComponents registers the components given with
+// --customRotor and --customReflector, for this invocation only.
+func registerCustomComponents() {
+	for _, definition := range customRotorsFlag {
+		name, wiring, notches, err := parseCustomRotor(definition)
+		if err != nil {
+			glog.Fatalf("%s", err)
 		}
-		e.InstallReflector(r)
-		glog.Infof("Reflector: %v", reflectorFlag)
+		if err := enigma.RegisterRotor(name, wiring, []byte(notches)...); err != nil {
+			glog.Fatalf("%s", err)
+		}
+		glog.Infof("Custom rotor %v: %v, turnover at %q", name, wiring, notches)
 	}
-
-	// Install the rotors.
-	if len(rotorsFlag) != 3 {
-		glog.Fatalf("This Enigma needs 3 rotors, but got rotors %v", rotorsFlag)
+	for _, definition := range customReflectorsFlag {
+		name, wiring, err := parseCustomReflector(definition)
+		if err != nil {
+			glog.Fatalf("%s", err)
+		}
+		if err := enigma.RegisterReflector(name, wiring); err != nil {
+			glog.Fatalf("%s", err)
+		}
+		glog.Infof("Custom reflector %v: %v", name, wiring)
 	}
-	var rotors [3]enigma.Rotor
-	for i, rname := range rotorsFlag {
-		r, ok := enigma.Rotors[rname]
-		if !ok {
-			glog.Fatalf("Rotor %v does not exist; options are %v", rname, enigma.RotorNames())
+}
+
+// applySettingsFile sets --model and --settings from --settingsFile, if given,
+// or from its profile --profileName. Those flags, if given too, take
+// precedence over the file.
+func applySettingsFile(cmd *cobra.Command) {
+	if settingsFileFlag == "" {
+		if profileNameFlag != "" {
+			glog.Fatalf("--profileName needs a --settingsFile to read the profile from")
 		}
-		rotors[i] = r
+		return
+	}
+	contents, err := ioutil.ReadFile(settingsFileFlag)
+	if err != nil {
+		glog.Fatalf("Could not read --settingsFile: %s", err)
+	}
+	model, settings, err := parseSettingsFile(string(contents), profileNameFlag)
+	if err != nil {
+		glog.Fatalf("Got invalid --settingsFile: %s", err)
+	}
+	if model != "" && !cmd.Flags().Changed("model") {
+		modelFlag = model
+		modelFromFile = true
+	}
+	if settings != "" && settingsFlag == "" {
+		settingsFlag = settings
+		settingsFromFile = true
+	}
+}
+
+// applySettingsFlag sets the machine flags from --settings, if given.
+func applySettingsFlag() {
+	if settingsFlag == "" {
+		return
+	}
+	state, err := enigma.ParseSettings(settingsFlag)
+	if err != nil {
+		glog.Fatalf("Got invalid --settings: %s", err)
+	}
+	reflectorFlag = state.Reflector.Name
+	rotorsFlag = nil
+	for _, rotor := range state.Rotors {
+		rotorsFlag = append(rotorsFlag, rotor.Name)
+	}
+	ringSettingsFlag = strings.Split(state.RingSettings, "")
+	rotorPositionsFlag = strings.Split(state.Positions, "")
+	plugPairsFlag = state.PlugPairs
+	reflectorPositionFlag = state.ReflectorPosition
+}
+
+// applyModelDefaults replaces the defaults of the machine flags of `cmd` that
+// weren't given with those of `model`, so that --model alone sets up a
+// working machine: its default reflector and rotors, and ring settings and
+// positions at its first symbol (e.g. 'A') for each of its rotor slots.
+// --settings sets all of these, so there is nothing to default then.
+func applyModelDefaults(cmd *cobra.Command, model enigma.Model) {
+	if settingsFlag != "" {
+		return
+	}
+	if !cmd.Flags().Changed("reflector") {
+		reflectorFlag = model.DefaultReflector
+	}
+	if !cmd.Flags().Changed("rotors") {
+		rotorsFlag = model.DefaultRotors
+	}
+	first := make([]string, model.RotorSlots)
+	for i := range first {
+		first[i] = string(model.Alphabet[0])
 	}
-	e.InstallRotors(rotors[:])
+	if !cmd.Flags().Changed("ringSettings") {
+		ringSettingsFlag = first
+	}
+	if !cmd.Flags().Changed("positions") {
+		rotorPositionsFlag = first
+	}
+}
+
+// setUpEnigma creates an Enigma configured according to the machine flags of
+// `cmd` (see addMachineFlags).
+func setUpEnigma(cmd *cobra.Command) enigma.Enigma {
+	loadComponentFile()
+	registerCustomComponents()
+	applySettingsFile(cmd)
+	applySettingsFlag()
+	model, err := parseModel(modelFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	applyModelDefaults(cmd, model)
+	glog.Infof("Model: %v", modelFlag)
+
+	// Install the reflector and rotors.
+	e, err := enigma.NewModel(modelFlag, reflectorFlag, rotorsFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	glog.Infof("Reflector: %v", reflectorFlag)
 	glog.Infof("Rotors: %v", rotorsFlag)
+	reflectorPosition, err := parseReflectorPosition(model, reflectorPositionFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	if err := e.SetReflectorPosition(reflectorPosition); err != nil {
+		glog.Fatalf("%s", err)
+	}
+	glog.Infof("Reflector position: %q", reflectorPosition)
 
 	// Set the ring settings.
-	if len(ringSettingsFlag) != 3 {
-		glog.Fatalf("This Enigma needs 3 ring settings. Got ring settings %v", ringSettingsFlag)
-	}
-	var ringSettings [3]byte
-	for i, flag := range ringSettingsFlag {
-		// First attempt to interpret `setting` as a number.
-		val, err := strconv.Atoi(flag)
-		if err == nil {
-			if val < 1 || val > 26 {
-				glog.Fatalf("Got invalid ring setting number: %v", val)
-			}
-			ringSettings[i] = byte(val) + 'A' - 1
-			continue
+	ringSettings, err := parseRingSettings(model, ringSettingsFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	if err := e.SetRingSettings(ringSettings); err != nil {
+		glog.Fatalf("%s", err)
+	}
+	glog.Infof("Ring settings: %q", ringSettings)
+
+	// Set the plug pairs.
+	if !model.Plugboard && len(plugPairsFlag) > 0 {
+		glog.Fatalf("This Enigma has no plugboard, but got plug pairs %v", plugPairsFlag)
+	}
+	plugboard, err := parsePlugboard(plugPairsFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	e.SetPlugboard(plugboard)
+	glog.Infof("Plugboard: %v", plugPairsFlag)
+
+	// Set the message key.
+	positions, err := parseRotorPositions(model, rotorPositionsFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	if err := e.SetRotorPositions(positions); err != nil {
+		glog.Fatalf("%s", err)
+	}
+	glog.Infof("Rotor positions: %q", positions)
+
+	// Check the settings against history, if requested.
+	if strictHistoryFlag != "" {
+		if modelFlag != "I" {
+			glog.Fatalf("--strictHistory only knows the history of the Enigma I")
+		}
+		date, err := time.Parse("2006-01-02", strictHistoryFlag)
+		if err != nil {
+			glog.Fatalf("Got invalid date for --strictHistory: %s", err)
+		}
+		anachronisms := enigma.CheckHistory(date, reflectorFlag, rotorsFlag, len(plugPairsFlag))
+		if len(anachronisms) > 0 {
+			glog.Fatalf("These settings could not have been used on %v: %v",
+				strictHistoryFlag, strings.Join(anachronisms, "; "))
 		}
+	}
+
+	return e
+}
 
-		// Now attempt to interpret `setting` as a single character.
-		if len(flag) > 1 {
-			glog.Fatalf("Got invalid ring setting character: %v", flag)
+func crypt(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	e := setUpEnigma(cmd)
+	if dryRunFlag {
+		printDryRun(cmd, e)
+		return
+	}
+	sinks, err := newSinks()
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+
+	// Take the message from the clipboard, if requested.
+	text := strings.Join(args, " ")
+	if pasteFlag {
+		if len(args) > 0 {
+			glog.Fatalf("Got both --paste and a message %v; use only one", args)
 		}
-		b := flag[0]
-		if b < 'A' || b > 'Z' {
-			glog.Fatalf("Got invalid ring setting character: %v", b)
+		text, err = readClipboard()
+		if err != nil {
+			glog.Fatalf("Could not paste message: %s", err)
 		}
-		ringSettings[i] = b
 	}
-	e.SetRingSettings(ringSettings[:])
-	glog.Infof("Ring settings: %q, %q, %q", ringSettings[0], ringSettings[1], ringSettings[2])
 
-	// Set the plug pairs.
-	var plugboard enigma.Plugboard
-	for _, flag := range plugPairsFlag {
-		if len(flag) != 2 {
-			glog.Fatalf("All plug pairs must be 2 letters, such as 'AB'. Got: '%v'", flag)
+	// Turn a spelled-out message back into letters, if requested.
+	if spelledFlag {
+		text, err = enigma.ParsePhonetic(text)
+		if err != nil {
+			glog.Fatalf("Could not read spelled-out message: %s", err)
+		}
+	}
+
+	// Clean up the transcription, if requested.
+	if cleanFlag {
+		var problems []enigma.TranscriptionProblem
+		text, problems = enigma.CleanTranscription(text, letterCountFlag)
+		for _, problem := range problems {
+			glog.Warningf("Transcription problem: %v", problem)
 		}
-		if err := plugboard.AddPlugPair(flag[0], flag[1]); err != nil {
-			glog.Fatalf("Could not add plug pair: %s", err)
+	}
+	args = strings.Fields(text)
+	if len(args) == 0 {
+		glog.Fatalf("Got no message to type")
+	}
+
+	var substitute byte
+	if substituteFlag != "" {
+		if len(substituteFlag) != 1 {
+			glog.Fatalf("--substitute must be a single key, got %q", substituteFlag)
 		}
+		substitute = substituteFlag[0]
 	}
-	e.SetPlugboard(plugboard)
-	glog.Infof("Plugboard: %v", plugPairsFlag)
 
-	// Set the message key.
-	if len(rotorPositionsFlag) != 3 {
-		glog.Fatalf("This Enigma needs 3 rotor positions, got %v", rotorPositionsFlag)
+	// Hold the message to the longest that procedure allowed, if requested.
+	maxLength := maxLengthFlag
+	if maxLength == 0 {
+		model, _ := parseModel(modelFlag) // Already checked by setUpEnigma.
+		maxLength = model.MaxMessageLength
 	}
-	var positions [3]byte
-	for i, flag := range rotorPositionsFlag {
-		if len(flag) != 1 {
-			glog.Fatalf(
-				"Every rotor position should be a single character, like 'A'. Got %v", rotorPositionsFlag)
+	parts := []string{strings.Join(args, " ")}
+	switch lengthPolicyFlag {
+	case "allow":
+	case "error":
+		if err := enigma.CheckMessageLength(parts[0], maxLength); err != nil {
+			glog.Fatalf("%s", err)
 		}
-		b := flag[0]
-		if b < 'A' || b > 'Z' {
-			glog.Fatalf("Got invalid rotor position: %q", b)
+	case "split":
+		parts = enigma.SplitMessage(parts[0], maxLength)
+	default:
+		glog.Fatalf("--lengthPolicy must be 'allow', 'error' or 'split', got %q", lengthPolicyFlag)
+	}
+
+	// Finally, type the message! Each part starts from the configured
+	// positions.
+	t := newTracer()
+	for i, part := range parts {
+		if i > 0 {
+			e.Reset()
+			glog.Infof("Typing part %v of %v", i+1, len(parts))
+		}
+		result := typeGroups(e, strings.Fields(part), substitute, t)
+		for _, s := range sinks {
+			if err := s.write(result); err != nil {
+				glog.Fatalf("Could not write the result to %v: %s", s.name, err)
+			}
 		}
-		positions[i] = b
 	}
-	e.SetRotorPositions(positions[:])
-	glog.Infof("Rotor positions: %q, %q, %q", positions[0], positions[1], positions[2])
+	if t != nil {
+		t.writeHTML(strings.Join(args, " "))
+	}
+}
 
-	// Finally, type the message!
-	for _, arg := range args {
-		out := enigma.Type(e, arg)
-		if debugFlag {
-			glog.Infof("%s = %s", arg, out)
+// typeGroups types each of `groups` on `e` and returns the results, separated
+// by spaces. If `t` isn't nil, it gets the trace of every key press.
+func typeGroups(e enigma.Enigma, groups []string, substitute byte, t *tracer) string {
+	outs := make([]string, len(groups))
+	for i, group := range groups {
+		var err error
+		if t != nil {
+			outs[i], err = enigma.TypeTraced(e, group, substitute, t.trace)
 		} else {
-			fmt.Printf("%s ", out)
+			outs[i], err = enigma.TypeChecked(e, group, substitute)
 		}
+		if err != nil {
+			glog.Fatalf("Could not type %q: %s", group, err)
+		}
+		if debugFlag {
+			glog.Infof("%s = %s", group, outs[i])
+		}
+	}
+	glog.Infof("Rotor positions after typing: %q", e.RotorPositions())
+	if p := e.ReflectorPosition(); p != 0 {
+		glog.Infof("Reflector position after typing: %q", p)
 	}
-	fmt.Println("")
+	return strings.Join(outs, " ")
+}
+
+// addMachineFlags adds the flags that configure the Enigma (see setUpEnigma)
+// to `cmd`.
+func addMachineFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&modelFlag, "model", "I", fmt.Sprintf(
+		"The Enigma model to use, which determines the components that fit. Options are %v",
+		enigma.ModelNames()),
+	)
+	cmd.PersistentFlags().StringVar(&reflectorFlag, "reflector", "B", fmt.Sprintf(
+		"The reflector called for by the code book. Defaults to the model's usual one. Options are %v",
+		enigma.ReflectorNames()),
+	)
+	cmd.PersistentFlags().StringSliceVar(&rotorsFlag, "rotors", []string{"I", "II", "III"}, fmt.Sprintf(
+		`The rotors (in left-to-right order) called for by the code book: 3, or 4 for the M4. Defaults
+to the model's usual ones. Options are %v`,
+		enigma.RotorNames()),
+	)
+	cmd.PersistentFlags().StringSliceVar(&ringSettingsFlag, "ringSettings", []string{"A", "A", "A"},
+		`The ring setting for the rotors (in left-to-right order) called for by the code book. May be 
+either characters (e.g. 'A') or numbers (e.g. 1). Defaults to the first for every rotor`)
+	cmd.PersistentFlags().StringSliceVar(&plugPairsFlag, "plugPairs", []string{},
+		`The plug pairs for the Enigma's plugboard. For example 'AB,CD' would indicate the plugboard
+connects A<->B and C<->D`)
+	cmd.PersistentFlags().StringSliceVar(&rotorPositionsFlag, "positions", []string{"A", "A", "A"},
+		"The position of the Enigma's rotors. Also known as the 'key'. Defaults to the first for every rotor")
+	cmd.PersistentFlags().StringVar(&reflectorPositionFlag, "reflectorPosition", "",
+		"The position of the reflector, for models where it can be set, such as the G. Defaults to 'A'")
+	cmd.PersistentFlags().StringVar(&settingsFlag, "settings", "",
+		`The whole key on one line, instead of --reflector, --rotors, --ringSettings, --positions, 
+--plugPairs and --reflectorPosition, e.g. 'B I II III / 01 01 01 / AAA / AB CD EF'`)
+	cmd.PersistentFlags().StringVar(&settingsFileFlag, "settingsFile", "",
+		`A file holding the model and --settings, as written by 'enigma setup --save'. --model and
+--settings, if also given, take precedence`)
+	cmd.PersistentFlags().StringVar(&profileNameFlag, "profileName", "",
+		"A named profile in --settingsFile to use, instead of the settings outside any profile")
+	cmd.PersistentFlags().StringVar(&strictHistoryFlag, "strictHistory", "",
+		`A date (e.g. 1939-09-01). If given, refuse settings that the German Army's code books could
+not have called for on that date, such as rotors that weren't in service yet`)
+	cmd.PersistentFlags().StringVar(&componentFileFlag, "componentFile", "",
+		"A JSON file defining custom rotors and reflectors to make available, in addition to the historical ones")
+	cmd.PersistentFlags().StringSliceVar(&customRotorsFlag, "customRotor", []string{},
+		`A custom rotor to make available, written like 'NAME=WIRING@NOTCHES', e.g. 
+'Mine=EKMFLGDQVZNTOWYHXUSPAIBRCJ@Q'. May be repeated`)
+	cmd.PersistentFlags().StringSliceVar(&customReflectorsFlag, "customReflector", []string{},
+		`A custom reflector to make available, written like 'NAME=WIRING', e.g. 
+'Mine=YRUHQSLDPXNGOKMIEBFZCWVJAT'. May be repeated`)
 }
 
 func main() {
@@ -135,25 +421,145 @@ func main() {
 		Long: `In an Enigma, encrypting and decrypting are the same operation, just with different 
 input. Use 'crypt' and pass in the message that you want to encrypt or decrypt. Use 
 flags to set things like the rotors, plugboard, and so forth.`,
-		Args: cobra.MinimumNArgs(1),
+		Args: cobra.ArbitraryArgs,
 		Run:  crypt,
 	}
-	cmdCrypt.PersistentFlags().StringVar(&reflectorFlag, "reflector", "B", fmt.Sprintf(
-		"The reflector called for by the code book. Options are %v",
-		enigma.ReflectorNames()),
-	)
-	cmdCrypt.PersistentFlags().StringSliceVar(&rotorsFlag, "rotors", []string{"I", "II", "III"}, fmt.Sprintf(
-		"The 3 rotors (in left-to-right order) called for by the code book. Options are %v",
-		enigma.RotorNames()),
-	)
-	cmdCrypt.PersistentFlags().StringSliceVar(&ringSettingsFlag, "ringSettings", []string{"A", "A", "A"},
-		`The ring setting for the rotors (in left-to-right order) called for by the code book. May be 
-either characters (e.g. 'A') or numbers (e.g. 1)`)
-	cmdCrypt.PersistentFlags().StringSliceVar(&plugPairsFlag, "plugPairs", []string{},
-		`The plug pairs for the Enigma's plugboard. For example 'AB,CD' would indicate the plugboard
-connects A<->B and C<->D`)
-	cmdCrypt.PersistentFlags().StringSliceVar(&rotorPositionsFlag, "positions", []string{"A", "A", "A"},
-		"The position of the Enigma's rotors. Also known as the 'key'.")
+	addMachineFlags(cmdCrypt)
+	cmdCrypt.PersistentFlags().BoolVar(&copyFlag, "copy", false,
+		"Also copy the result to the system clipboard")
+	cmdCrypt.PersistentFlags().StringArrayVar(&outFlag, "out", nil,
+		`Where to write the result: 'stdout', 'clipboard', or a file to append to. Repeat to write to
+several. Options after the destination override the formatting flags for it, e.g.
+--out=archive.txt,groupSize=5,groupsPerLine=10 --out=stdout,spell. Defaults to stdout`)
+	cmdCrypt.PersistentFlags().BoolVar(&pasteFlag, "paste", false,
+		"Read the message from the system clipboard instead of the command line")
+	cmdCrypt.PersistentFlags().BoolVar(&cleanFlag, "clean", false,
+		`Clean up a transcribed message before typing it: strip line numbers, convert to upper case,
+and drop anything that isn't a letter, reporting what was changed`)
+	cmdCrypt.PersistentFlags().IntVar(&letterCountFlag, "letterCount", 0,
+		"With --clean: the letter count (Buchstabenzahl) stated in the message header, to check against")
+	cmdCrypt.PersistentFlags().StringVar(&substituteFlag, "substitute", "",
+		"Type this key (e.g. 'X') instead of anything that isn't a key. By default, such characters are an error")
+	cmdCrypt.PersistentFlags().IntVar(&groupSizeFlag, "groupSize", 0,
+		"Regroup the result into groups of this many letters. By default, the message's groups are kept")
+	cmdCrypt.PersistentFlags().StringVar(&groupSeparatorFlag, "groupSeparator", "space",
+		"What to put between groups: 'space', 'newline', or any other text")
+	cmdCrypt.PersistentFlags().IntVar(&groupsPerLineFlag, "groupsPerLine", 0,
+		"Start a new line after this many groups (historically 5 or 10). By default, there is one line")
+	cmdCrypt.PersistentFlags().StringVar(&continuationFlag, "continuation", "",
+		"With --groupsPerLine: a marker to end every line that the message continues after, such as '='")
+	cmdCrypt.PersistentFlags().BoolVar(&spellFlag, "spell", false,
+		"Spell out the result in the German spelling alphabet (Anton, Berta, Cäsar, ...), for reading aloud")
+	cmdCrypt.PersistentFlags().BoolVar(&spelledFlag, "spelled", false,
+		`The message is spelled out in the German spelling alphabet, with groups separated by '/', 
+e.g. 'Anton Berta / Cäsar'`)
+	cmdCrypt.PersistentFlags().BoolVar(&dryRunFlag, "dryRun", false,
+		"Print the machine the flags set up, and which flag each setting came from, without typing anything")
+	cmdCrypt.PersistentFlags().BoolVar(&traceFlag, "trace", false,
+		"Print the path of the current through the machine for every key typed, one line per key")
+	cmdCrypt.PersistentFlags().StringVar(&traceHTMLFlag, "traceHTML", "",
+		"Write the path of every key typed to this file as an HTML page, to scrub through in a browser")
+	cmdCrypt.PersistentFlags().StringVar(&traceFormatFlag, "traceFormat", "text",
+		`With --trace: 'text' for a readable line, 'json' for a JSON object per key (JSON Lines), or
+'dot' for a Graphviz DOT graph per key`)
+	cmdCrypt.PersistentFlags().IntVar(&maxLengthFlag, "maxLength", 0,
+		"The most letters allowed in one message. Defaults to the limit procedure set for --model, if any")
+	cmdCrypt.PersistentFlags().StringVar(&lengthPolicyFlag, "lengthPolicy", "allow",
+		`What to do with a message longer than --maxLength: 'allow' it, fail with an 'error', or 'split'
+it into parts, each typed from the configured positions and written separately. Operators
+chose a new message key for every part; to do that, type the parts one by one instead`)
+
+	var cmdVerify = &cobra.Command{
+		Use:   "verify",
+		Short: "Check that a plaintext encrypts to a given ciphertext",
+		Long: `Encrypts the plaintext in --plain and compares the result letter by letter with the 
+ciphertext in --cipher, pointing out every position where they differ. This is useful for 
+checking transcriptions of historical messages. Use the same flags as for 'crypt' to set up 
+the machine.`,
+		Args: cobra.NoArgs,
+		Run:  verify,
+	}
+	addMachineFlags(cmdVerify)
+	cmdVerify.Flags().StringVar(&plainFileFlag, "plain", "", "File containing the plaintext")
+	cmdVerify.Flags().StringVar(&cipherFileFlag, "cipher", "", "File containing the ciphertext")
+
+	var cmdVerifyArchive = &cobra.Command{
+		Use:   "verify-archive archive.jsonl",
+		Short: "Check every message of an archive against its key sheet",
+		Long: `Re-encrypts every message in an archive file whose key sheet, message key and plaintext 
+are known, and compares the result with its ciphertext, like 'verify' does. The archive holds a 
+JSON object per line, naming each message's model and key sheet; key sheets are read from 
+--keySheets, as one JSON file per key sheet, such as those 'simulate --truth' writes.`,
+		Args: cobra.ExactArgs(1),
+		Run:  verifyArchive,
+	}
+	cmdVerifyArchive.Flags().StringVar(&keySheetsFlag, "keySheets", "",
+		"Directory holding the key sheets, as <name>.json")
+	cmdVerifyArchive.Flags().StringVar(&passphraseFileFlag, "passphraseFile", "",
+		"File holding the passphrase of sealed key sheets")
+
+	var cmdSetup = &cobra.Command{
+		Use:   "setup",
+		Short: "Interactively choose the machine settings",
+		Long: `Asks step by step for the reflector, rotors, ring settings, plug pairs and rotor 
+positions, checking each answer, and then prints the 'crypt' flags for those settings. Names
+of models and components may be abbreviated to their start; end an answer with a tab or '?' to
+list the names it could be. With --save, also writes the settings to a file for --settingsFile;
+with --profileName too, to that named profile in the file, keeping its other profiles.`,
+		Args: cobra.NoArgs,
+		Run:  setup,
+	}
+	cmdSetup.Flags().StringVar(&saveFlag, "save", "",
+		"A file to write the chosen settings to, for use with --settingsFile")
+	cmdSetup.Flags().StringVar(&profileNameFlag, "profileName", "",
+		"A named profile to write the settings to in the --save file, replacing one of the same name")
+
+	var cmdComponents = &cobra.Command{
+		Use:   "components",
+		Short: "List the historical rotors and reflectors",
+		Long: `Lists the rotors and reflectors this Enigma knows, with the machines they were issued 
+for, the year they were introduced (approximate for some), their wiring and their notches.`,
+		Args: cobra.NoArgs,
+		Run:  components,
+	}
+	cmdComponents.Flags().StringVar(&componentsModelFlag, "model", "", fmt.Sprintf(
+		"Only list the components that fit this model. Options are %v", enigma.ModelNames()))
+	cmdComponents.Flags().StringVar(&componentFileFlag, "componentFile", "",
+		"A JSON file defining custom rotors and reflectors to list, in addition to the historical ones")
+
+	var cmdSeal = &cobra.Command{
+		Use:   "seal file sealed",
+		Short: "Encrypt a file, such as a key sheet, with a passphrase",
+		Long: `Writes the contents of a file, such as a key sheet or ground truth, to a sealed file that 
+can only be read with the passphrase in --passphraseFile. Instructors can hand out the keys to an 
+exercise this way, and reveal the passphrase when it ends. 'verify-archive' reads sealed key 
+sheets, and 'unseal' recovers the contents.`,
+		Args: cobra.ExactArgs(2),
+		Run:  seal,
+	}
+	cmdSeal.Flags().StringVar(&passphraseFileFlag, "passphraseFile", "",
+		"File holding the passphrase, on its first line")
+
+	var cmdUnseal = &cobra.Command{
+		Use:   "unseal sealed",
+		Short: "Print the contents of a sealed file",
+		Long:  `Opens a file sealed by 'seal' or 'simulate' with the passphrase in --passphraseFile.`,
+		Args:  cobra.ExactArgs(1),
+		Run:   unseal,
+	}
+	cmdUnseal.Flags().StringVar(&passphraseFileFlag, "passphraseFile", "",
+		"File holding the passphrase, on its first line")
+
+	var cmdAbout = &cobra.Command{
+		Use:   "about",
+		Short: "Show how this binary was built",
+		Long: `Prints the Go version this binary was built with. With --features, also lists the 
+subsystems that were compiled in; build with '-tags=cipheronly' for a small binary with only 
+the cipher commands.`,
+		Args: cobra.NoArgs,
+		Run:  about,
+	}
+	cmdAbout.Flags().BoolVar(&featuresFlag, "features", false, "List the subsystems compiled into this binary")
 
 	var rootCmd = &cobra.Command{
 		Use:   "enigma",
@@ -162,6 +568,8 @@ connects A<->B and C<->D`)
 1938. See usage examples at https://github.com/rjhacks/enigma.`,
 	}
 	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "Set to `true` for debug output")
-	rootCmd.AddCommand(cmdCrypt)
+	rootCmd.AddCommand(cmdCrypt, cmdVerify, cmdVerifyArchive, cmdSetup, cmdComponents, cmdSeal,
+		cmdUnseal, cmdAbout)
+	addExerciseCommands(rootCmd)
 	rootCmd.Execute()
 }
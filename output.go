@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rjhacks/enigma/enigma"
+)
+
+var outFlag []string
+
+// A sink is a place that 'crypt' writes its result to, in a format of its
+// own: the standard output, the clipboard, or a file.
+type sink struct {
+	// "stdout", "clipboard", or the path of a file.
+	name string
+
+	format outputFormat
+	spell  bool
+}
+
+// parseSink parses an --out value: where to write the result, optionally
+// followed by formatting options that override the formatting flags for it,
+// such as "archive.txt,groupSize=5,groupsPerLine=10" or "stdout,spell".
+func parseSink(spec string, format outputFormat, spell bool) (sink, error) {
+	parts := strings.Split(spec, ",")
+	s := sink{name: parts[0], format: format, spell: spell}
+	if s.name == "" || s.name == "-" {
+		s.name = "stdout"
+	}
+	for _, option := range parts[1:] {
+		kv := strings.SplitN(option, "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		var err error
+		switch kv[0] {
+		case "groupSize":
+			s.format.groupSize, err = strconv.Atoi(value)
+		case "groupsPerLine":
+			s.format.groupsPerLine, err = strconv.Atoi(value)
+		case "groupSeparator":
+			s.format.separator = parseSeparator(value)
+		case "continuation":
+			s.format.continuation = value
+		case "spell":
+			s.spell = value == "" || value == "true"
+		default:
+			return sink{}, fmt.Errorf(
+				"Unknown option %q in --out=%v; options are groupSize, groupsPerLine, groupSeparator, continuation and spell",
+				kv[0], spec)
+		}
+		if err != nil || s.format.groupSize < 0 || s.format.groupsPerLine < 0 {
+			return sink{}, fmt.Errorf("Got invalid option %q in --out=%v", option, spec)
+		}
+	}
+	return s, nil
+}
+
+// newSinks returns the sinks set by --out, or by default the standard output
+// (unless debugging). --copy adds the clipboard, if --out doesn't list it.
+func newSinks() ([]sink, error) {
+	format, err := newOutputFormat()
+	if err != nil {
+		return nil, err
+	}
+	var sinks []sink
+	clipboard := false
+	for _, spec := range outFlag {
+		s, err := parseSink(spec, format, spellFlag)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+		clipboard = clipboard || s.name == "clipboard"
+	}
+	if len(outFlag) == 0 && !debugFlag {
+		sinks = append(sinks, sink{name: "stdout", format: format, spell: spellFlag})
+	}
+	if copyFlag && !clipboard {
+		sinks = append(sinks, sink{name: "clipboard", format: format, spell: spellFlag})
+	}
+	return sinks, nil
+}
+
+// write lays out `text`, whose groups are separated by whitespace, in the
+// sink's format, and writes it to the sink. Files are appended to, so that
+// they collect the results of several runs.
+func (s sink) write(text string) error {
+	result := s.format.format(text)
+	if s.spell {
+		result = enigma.SpellPhonetic(result)
+	}
+	switch s.name {
+	case "stdout":
+		fmt.Println(result)
+		return nil
+	case "clipboard":
+		return writeClipboard(result)
+	}
+	f, err := os.OpenFile(s.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var passphraseFileFlag string
+
+// readPassphrase returns the passphrase in --passphraseFile, or "" if there
+// is none. Only the first line of the file counts.
+func readPassphrase() string {
+	if passphraseFileFlag == "" {
+		return ""
+	}
+	contents, err := ioutil.ReadFile(passphraseFileFlag)
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", passphraseFileFlag, err)
+	}
+	passphrase := strings.TrimSpace(strings.SplitN(string(contents), "\n", 2)[0])
+	if passphrase == "" {
+		glog.Fatalf("%v holds no passphrase", passphraseFileFlag)
+	}
+	return passphrase
+}
+
+// writeMaybeSealed writes `data` to the file at `path`, sealed with the
+// passphrase in --passphraseFile if there is one.
+func writeMaybeSealed(path string, data []byte) {
+	mode := os.FileMode(0644)
+	if passphrase := readPassphrase(); passphrase != "" {
+		var sealed bytes.Buffer
+		if err := enigma.Seal(&sealed, data, passphrase); err != nil {
+			glog.Fatalf("Could not seal %v: %s", path, err)
+		}
+		data, mode = sealed.Bytes(), 0600
+	}
+	if err := ioutil.WriteFile(path, data, mode); err != nil {
+		glog.Fatalf("Could not write %v: %s", path, err)
+	}
+}
+
+func seal(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	if passphraseFileFlag == "" {
+		glog.Fatalf("--passphraseFile is required")
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", args[0], err)
+	}
+	if enigma.IsSealed(data) {
+		glog.Fatalf("%v is already sealed", args[0])
+	}
+	writeMaybeSealed(args[1], data)
+}
+
+func unseal(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	if passphraseFileFlag == "" {
+		glog.Fatalf("--passphraseFile is required")
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", args[0], err)
+	}
+	data, err = enigma.Unseal(data, readPassphrase())
+	if err != nil {
+		glog.Fatalf("Could not unseal %v: %s", args[0], err)
+	}
+	os.Stdout.Write(data)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rjhacks/enigma/enigma"
+)
+
+// parseModel returns the Enigma model with the given name.
+func parseModel(name string) (enigma.Model, error) {
+	model, ok := enigma.Models[name]
+	if !ok {
+		return model, fmt.Errorf("Model '%v' does not exist; options are %v", name, enigma.ModelNames())
+	}
+	return model, nil
+}
+
+// parseReflector checks that the reflector with the given name fits `model`.
+func parseReflector(model enigma.Model, name string) error {
+	for _, r := range model.Reflectors {
+		if r == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("Reflector '%v' does not fit this Enigma; options are %v", name, model.Reflectors)
+}
+
+// parseRingSettings turns ring settings, given either as letters (e.g. "A")
+// or as numbers (e.g. "1"), into letters for `model`. On the Enigma Z, whose
+// ring settings are digits, the digits are taken as they are.
+func parseRingSettings(model enigma.Model, settings []string) ([]byte, error) {
+	if len(settings) != model.RotorSlots {
+		return nil, fmt.Errorf(
+			"This Enigma needs %v ring settings. Got ring settings %v", model.RotorSlots, settings)
+	}
+	ringSettings := make([]byte, len(settings))
+	for i, setting := range settings {
+		// First attempt to interpret `setting` as a single character.
+		if len(setting) == 1 && model.Alphabet.Contains(setting[0]) {
+			ringSettings[i] = setting[0]
+			continue
+		}
+
+		// Now attempt to interpret `setting` as a number.
+		val, err := strconv.Atoi(setting)
+		if err == nil {
+			if val < 1 || val > len(model.Alphabet) {
+				return nil, fmt.Errorf("Got invalid ring setting number: %v", val)
+			}
+			ringSettings[i] = model.Alphabet[val-1]
+			continue
+		}
+		return nil, fmt.Errorf("Got invalid ring setting: %q", setting)
+	}
+	return ringSettings, nil
+}
+
+// parsePlugboard returns a plugboard connecting the given pairs of letters,
+// such as "AB".
+func parsePlugboard(pairs []string) (enigma.Plugboard, error) {
+	var plugboard enigma.Plugboard
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			return plugboard, fmt.Errorf(
+				"All plug pairs must be 2 letters, such as 'AB'. Got: '%v'", pair)
+		}
+		if err := plugboard.AddPlugPair(pair[0], pair[1]); err != nil {
+			return plugboard, fmt.Errorf("Could not add plug pair: %s", err)
+		}
+	}
+	return plugboard, nil
+}
+
+// parsePosition turns a single position, such as "A", into a byte for
+// `model`.
+func parsePosition(model enigma.Model, position string) (byte, error) {
+	if len(position) != 1 || !model.Alphabet.Contains(position[0]) {
+		return 0, fmt.Errorf(
+			"Every position should be a single character from %v. Got %q", model.Alphabet, position)
+	}
+	return position[0], nil
+}
+
+// parseReflectorPosition turns a reflector position, given as a letter, into a
+// byte for `model`. An empty position means the first position (e.g. 'A');
+// only models with a settable reflector accept any other.
+func parseReflectorPosition(model enigma.Model, position string) (byte, error) {
+	if position == "" {
+		return model.Alphabet[0], nil
+	}
+	if !model.SettableReflector {
+		return 0, fmt.Errorf("This Enigma's reflector can't be set to a position")
+	}
+	return parsePosition(model, position)
+}
+
+// parseRotorPositions turns rotor positions, given as letters, into bytes for
+// `model`.
+func parseRotorPositions(model enigma.Model, positions []string) ([]byte, error) {
+	if len(positions) != model.RotorSlots {
+		return nil, fmt.Errorf("This Enigma needs %v rotor positions, got %v", model.RotorSlots, positions)
+	}
+	result := make([]byte, len(positions))
+	for i, position := range positions {
+		b, err := parsePosition(model, position)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = b
+	}
+	return result, nil
+}
+
+// parseCustomRotor splits a custom rotor definition, such as
+// "Mine=EKMFLGDQVZNTOWYHXUSPAIBRCJ@Q", into its name, wiring and turnover
+// points. The turnover points may be left out, along with the '@'.
+func parseCustomRotor(definition string) (name, wiring, notches string, err error) {
+	parts := strings.SplitN(definition, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", "", fmt.Errorf(
+			"Custom rotors must be written like 'NAME=WIRING@NOTCHES'. Got %q", definition)
+	}
+	name, wiring = parts[0], parts[1]
+	if i := strings.IndexByte(wiring, '@'); i >= 0 {
+		wiring, notches = wiring[:i], wiring[i+1:]
+	}
+	return name, wiring, notches, nil
+}
+
+// parseCustomReflector splits a custom reflector definition, such as
+// "Mine=YRUHQSLDPXNGOKMIEBFZCWVJAT", into its name and wiring.
+func parseCustomReflector(definition string) (name, wiring string, err error) {
+	parts := strings.SplitN(definition, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf(
+			"Custom reflectors must be written like 'NAME=WIRING'. Got %q", definition)
+	}
+	return parts[0], parts[1], nil
+}
+
+// A settings file holds a model and a --settings line, written by 'enigma
+// setup --save' and read with --settingsFile. It may also hold named
+// profiles, each with a model and settings of its own, selected with
+// --profileName. For example:
+//
+//	model: I
+//	settings: B I II III / 01 01 01 / AAA / AB CD EF
+//
+//	[profiles.navy-june6]
+//	model: M3
+//	settings: B III VI VIII / 01 08 13 / UZV / AN EZ HK IJ LR MQ OT PV SW UX
+//
+// Blank lines and lines starting with '#' are ignored.
+
+// profileHeader returns the line that starts the section of `profile`.
+func profileHeader(profile string) string {
+	return "[profiles." + profile + "]"
+}
+
+// checkProfileName returns an error if `profile` can't name a profile.
+func checkProfileName(profile string) error {
+	if profile == "" || strings.ContainsAny(profile, "[] \t") {
+		return fmt.Errorf("Profile names must be non-empty, without spaces or brackets. Got %q", profile)
+	}
+	return nil
+}
+
+// settingsSections splits a settings file into its sections: the lines
+// before the first profile, under the name "", then those of every profile,
+// by name, in the order they appear.
+func settingsSections(text string) (names []string, sections map[string][]string, err error) {
+	names = []string{""}
+	sections = map[string][]string{"": nil}
+	name := ""
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if !strings.HasPrefix(trimmed, "[profiles.") || !strings.HasSuffix(trimmed, "]") {
+				return nil, nil, fmt.Errorf("Settings file sections must be like '[profiles.NAME]'. Got %q", trimmed)
+			}
+			name = strings.TrimSuffix(strings.TrimPrefix(trimmed, "[profiles."), "]")
+			if err := checkProfileName(name); err != nil {
+				return nil, nil, err
+			}
+			if _, ok := sections[name]; ok {
+				return nil, nil, fmt.Errorf("Settings file has profile %q twice", name)
+			}
+			names = append(names, name)
+			sections[name] = nil
+			continue
+		}
+		sections[name] = append(sections[name], line)
+	}
+	return names, sections, nil
+}
+
+// parseSettingsFile reads the model and the --settings line from a settings
+// file, for the named `profile`, or outside any profile if it is "". Either
+// may be left out, in which case it is empty.
+func parseSettingsFile(text, profile string) (model, settings string, err error) {
+	names, sections, err := settingsSections(text)
+	if err != nil {
+		return "", "", err
+	}
+	lines, ok := sections[profile]
+	if !ok {
+		return "", "", fmt.Errorf("Settings file has no profile %q; options are %v", profile, names[1:])
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("Settings file lines must be like 'model: I'. Got %q", line)
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "model":
+			model = value
+		case "settings":
+			settings = value
+		default:
+			return "", "", fmt.Errorf("Settings file has unknown setting %q; options are model and settings", parts[0])
+		}
+	}
+	return model, settings, nil
+}
+
+// withSettings returns the settings file `text` with the model and settings
+// of `profile` (or, if it is "", those outside any profile) replaced by
+// `model` and `state`, keeping everything else. An empty `text` starts a new
+// file.
+func withSettings(text, profile, model string, state enigma.State) (string, error) {
+	names, sections, err := settingsSections(text)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := sections[profile]; !ok {
+		names = append(names, profile)
+	}
+	if text == "" {
+		sections[""] = []string{"# Written by 'enigma setup'."}
+	}
+	// Keep comments, but not the old settings.
+	var lines []string
+	for _, line := range sections[profile] {
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	sections[profile] = append(lines, "model: "+model, fmt.Sprintf("settings: %v", state))
+
+	var b strings.Builder
+	for _, name := range names {
+		for len(sections[name]) > 0 && strings.TrimSpace(sections[name][len(sections[name])-1]) == "" {
+			sections[name] = sections[name][:len(sections[name])-1]
+		}
+		if name != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(profileHeader(name) + "\n")
+		}
+		for _, line := range sections[name] {
+			b.WriteString(line + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
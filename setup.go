@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+// splitAnswer splits a wizard answer such as "I, II III" into its parts,
+// accepting both commas and whitespace as separators.
+func splitAnswer(answer string) []string {
+	return strings.FieldsFunc(answer, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\t'
+	})
+}
+
+// upper returns `parts` in upper case, for answers that consist of letters.
+func upper(parts []string) []string {
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		result[i] = strings.ToUpper(part)
+	}
+	return result
+}
+
+var saveFlag string
+
+// completions returns the options that start with `prefix`, ignoring case.
+func completions(prefix string, options []string) []string {
+	var found []string
+	for _, option := range options {
+		if strings.HasPrefix(strings.ToUpper(option), strings.ToUpper(prefix)) {
+			found = append(found, option)
+		}
+	}
+	return found
+}
+
+// complete expands every part of an answer that is an option, or the start of
+// just one option, ignoring case, into that option. Other parts are kept as
+// they are, for the answer's check to reject.
+func complete(parts []string, options []string) []string {
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		result[i] = part
+		found := completions(part, options)
+		for _, option := range found {
+			if strings.EqualFold(option, part) {
+				found = []string{option}
+				break
+			}
+		}
+		if len(found) == 1 {
+			result[i] = found[0]
+		}
+	}
+	return result
+}
+
+// ask prompts the user with `question` until they give an answer that `check`
+// accepts, and returns that answer split into parts (see splitAnswer). An empty
+// answer means `def`. If the input runs out before an accepted answer, it
+// returns the error for the last one. If there are `options` to choose from, each part may be
+// abbreviated to the start of just one of them, and an answer ending in a tab
+// or '?' lists the options that its last part could be.
+func ask(in *bufio.Scanner, out io.Writer, question, def string, options []string,
+	check func([]string) error) ([]string, error) {
+	for {
+		fmt.Fprintf(out, "%v [%v]: ", question, def)
+		answer := def
+		eof := !in.Scan()
+		if eof {
+			fmt.Fprintln(out)
+		} else if text := strings.TrimSpace(in.Text()); text != "" {
+			answer = text
+		}
+		if raw := strings.TrimRight(in.Text(), " "); !eof && len(options) > 0 &&
+			(strings.HasSuffix(raw, "\t") || strings.HasSuffix(raw, "?")) {
+			parts := splitAnswer(strings.TrimRight(raw, "\t?"))
+			last := ""
+			if len(parts) > 0 && !strings.HasSuffix(strings.TrimRight(raw, "?"), " ") {
+				last = parts[len(parts)-1]
+			}
+			fmt.Fprintf(out, "  Options: %v\n", strings.Join(completions(last, options), " "))
+			continue
+		}
+		parts := complete(splitAnswer(answer), options)
+		err := check(parts)
+		if err == nil {
+			return parts, nil
+		}
+		fmt.Fprintf(out, "  %s\n", err)
+		if eof {
+			// Out of input, so there's no way to get a better answer.
+			return nil, fmt.Errorf("Ran out of input: %s", err)
+		}
+	}
+}
+
+func setup(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	in := bufio.NewScanner(os.Stdin)
+	out := os.Stdout
+	fmt.Fprintln(out, "Let's set up an Enigma. Press enter to accept the [default].")
+	prompt := func(question, def string, options []string, check func([]string) error) []string {
+		parts, err := ask(in, out, question, def, options, check)
+		if err != nil {
+			glog.Fatalf("%s", err)
+		}
+		return parts
+	}
+
+	model := prompt(
+		fmt.Sprintf("Model (one of %v)", enigma.ModelNames()), "I", enigma.ModelNames(),
+		func(parts []string) error {
+			if len(parts) != 1 {
+				return fmt.Errorf("Please give a single model")
+			}
+			_, err := parseModel(parts[0])
+			return err
+		})
+	m := enigma.Models[model[0]]
+	slots := m.RotorSlots
+	first := string(m.Alphabet[0])
+	defaultPositions := strings.TrimSpace(strings.Repeat(first+" ", slots))
+
+	reflector := prompt(
+		fmt.Sprintf("Reflector (one of %v)", m.Reflectors), m.DefaultReflector, m.Reflectors,
+		func(parts []string) error {
+			if len(parts) != 1 {
+				return fmt.Errorf("Please give a single reflector")
+			}
+			return parseReflector(m, parts[0])
+		})
+	rotors := prompt(
+		fmt.Sprintf("%v rotors, left to right (from %v)", slots, m.Rotors),
+		strings.Join(m.DefaultRotors, " "), m.Rotors,
+		func(parts []string) error {
+			return m.Validate(reflector[0], parts)
+		})
+	ringSettings := prompt(
+		fmt.Sprintf("Ring settings, left to right (positions, or numbers 1-%v)", len(m.Alphabet)),
+		defaultPositions, nil,
+		func(parts []string) error {
+			_, err := parseRingSettings(m, upper(parts))
+			return err
+		})
+	var plugPairs []string
+	if m.Plugboard {
+		plugPairs = prompt(
+			"Plug pairs (e.g. AB CD), or '-' for none", "-", nil,
+			func(parts []string) error {
+				if len(parts) == 1 && parts[0] == "-" {
+					return nil
+				}
+				_, err := parsePlugboard(upper(parts))
+				return err
+			})
+		if len(plugPairs) == 1 && plugPairs[0] == "-" {
+			plugPairs = nil
+		}
+	}
+	reflectorPosition := []string{"A"}
+	if m.SettableReflector {
+		reflectorPosition = prompt(
+			"Reflector position", first, nil,
+			func(parts []string) error {
+				if len(parts) != 1 {
+					return fmt.Errorf("Please give a single reflector position")
+				}
+				_, err := parseReflectorPosition(m, strings.ToUpper(parts[0]))
+				return err
+			})
+	}
+	positions := prompt(
+		"Rotor positions, left to right", defaultPositions, nil,
+		func(parts []string) error {
+			_, err := parseRotorPositions(m, upper(parts))
+			return err
+		})
+
+	fmt.Fprintln(out, "\nAll set! Use these settings with:")
+	flags := fmt.Sprintf("--model=%v --reflector=%v --rotors=%v --ringSettings=%v --plugPairs=%v --positions=%v",
+		model[0], reflector[0], strings.Join(rotors, ","), strings.Join(upper(ringSettings), ","),
+		strings.Join(upper(plugPairs), ","), strings.Join(upper(positions), ","))
+	if m.SettableReflector {
+		flags += fmt.Sprintf(" --reflectorPosition=%v", strings.ToUpper(reflectorPosition[0]))
+	}
+	fmt.Fprintf(out, "  enigma crypt %v [message]\n", flags)
+
+	if saveFlag == "" {
+		return
+	}
+	if profileNameFlag != "" {
+		if err := checkProfileName(profileNameFlag); err != nil {
+			glog.Fatalf("%s", err)
+		}
+	}
+	ringLetters, _ := parseRingSettings(m, upper(ringSettings))
+	state := enigma.State{
+		Reflector:    enigma.Component{Name: reflector[0]},
+		RingSettings: string(ringLetters),
+		PlugPairs:    upper(plugPairs),
+		Positions:    strings.Join(upper(positions), ""),
+	}
+	for _, rotor := range rotors {
+		state.Rotors = append(state.Rotors, enigma.Component{Name: rotor})
+	}
+	if m.SettableReflector {
+		state.ReflectorPosition = strings.ToUpper(reflectorPosition[0])
+	}
+	existing, err := ioutil.ReadFile(saveFlag)
+	if err != nil && !os.IsNotExist(err) {
+		glog.Fatalf("Could not read --save: %s", err)
+	}
+	contents, err := withSettings(string(existing), profileNameFlag, model[0], state)
+	if err != nil {
+		glog.Fatalf("Could not update %v: %s", saveFlag, err)
+	}
+	if err := ioutil.WriteFile(saveFlag, []byte(contents), 0644); err != nil {
+		glog.Fatalf("Could not write --save: %s", err)
+	}
+	fmt.Fprintf(out, "or, with the settings saved to %v:\n", saveFlag)
+	if profileNameFlag != "" {
+		fmt.Fprintf(out, "  enigma crypt --settingsFile=%v --profileName=%v [message]\n", saveFlag, profileNameFlag)
+	} else {
+		fmt.Fprintf(out, "  enigma crypt --settingsFile=%v [message]\n", saveFlag)
+	}
+}
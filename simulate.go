@@ -0,0 +1,45 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var truthFileFlag string
+
+func simulate(cmd *cobra.Command, args []string) {
+	setUpLogging()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", args[0], err)
+	}
+	defer f.Close()
+	scenario, err := enigma.ReadScenario(f)
+	if err != nil {
+		glog.Fatalf("%v: %s", args[0], err)
+	}
+	archive, err := scenario.Run()
+	if err != nil {
+		glog.Fatalf("Could not run %v: %s", args[0], err)
+	}
+
+	fmt.Printf("# Traffic of scenario %v, %v messages\n\n", args[0], len(archive))
+	if err := enigma.WriteArchive(os.Stdout, archive); err != nil {
+		glog.Fatalf("Could not write archive: %s", err)
+	}
+	if truthFileFlag != "" {
+		var truth bytes.Buffer
+		if err := enigma.WriteGroundTruth(&truth, scenario.Key, archive); err != nil {
+			glog.Fatalf("Could not write %v: %s", truthFileFlag, err)
+		}
+		writeMaybeSealed(truthFileFlag, truth.Bytes())
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+)
+
+var traceFlag bool
+var traceFormatFlag string
+var traceHTMLFlag string
+
+// traceLabels names the components of the machine configured by the flags,
+// for showing a trace: the reflector, and the rotors by slot.
+type traceLabels struct {
+	reflector string
+	rotors    []string
+}
+
+// currentTraceLabels returns the labels of the machine set up by setUpEnigma.
+func currentTraceLabels() traceLabels {
+	reflector := reflectorFlag
+	if reflector == "" {
+		reflector = "UKW"
+	}
+	return traceLabels{reflector: reflector, rotors: rotorsFlag}
+}
+
+// formatTrace writes the path of a key press as one line, naming each
+// component the signal passes through, e.g.
+// "A >plug> A >ETW> A >III> C >II> D >I> F >B> S >I> ... >plug> B > lamp B".
+func formatTrace(trace *enigma.Trace, labels traceLabels) string {
+	var b strings.Builder
+	b.WriteByte(trace.Key)
+	for _, step := range trace.Steps {
+		label := step.Stage
+		switch step.Stage {
+		case enigma.PlugboardStage:
+			label = "plug"
+		case enigma.EntryWheelStage:
+			label = "ETW"
+		case enigma.RotorStage:
+			if step.Slot < len(labels.rotors) {
+				label = labels.rotors[step.Slot]
+			}
+		case enigma.ReflectorStage:
+			label = labels.reflector
+		}
+		fmt.Fprintf(&b, " >%v> %c", label, step.Signal)
+	}
+	fmt.Fprintf(&b, " > lamp %c", trace.Lamp)
+	return b.String()
+}
+
+// A tracer handles the traces of the keys that crypt types, according to
+// --trace and --traceHTML.
+type tracer struct {
+	// Prints each trace as it comes, for --trace.
+	print func(*enigma.Trace)
+
+	// Copies of all traces, in order, for --traceHTML.
+	traces []enigma.Trace
+}
+
+// newTracer returns a tracer for the tracing flags, or nil if none are set.
+func newTracer() *tracer {
+	if !traceFlag && traceHTMLFlag == "" {
+		return nil
+	}
+	t := &tracer{}
+	if traceFlag {
+		t.print = newTracePrinter()
+	}
+	return t
+}
+
+// trace handles the trace of one key press.
+func (t *tracer) trace(trace *enigma.Trace) {
+	if t.print != nil {
+		t.print(trace)
+	}
+	if traceHTMLFlag != "" {
+		// The trace is reused for the next key press, so keep a copy.
+		kept := *trace
+		kept.Positions = append([]byte(nil), trace.Positions...)
+		kept.Steps = append([]enigma.TraceStep(nil), trace.Steps...)
+		t.traces = append(t.traces, kept)
+	}
+}
+
+// writeHTML writes the traces of the message `msg` to --traceHTML, if given.
+func (t *tracer) writeHTML(msg string) {
+	if traceHTMLFlag == "" {
+		return
+	}
+	f, err := os.Create(traceHTMLFlag)
+	if err != nil {
+		glog.Fatalf("Could not write traces: %s", err)
+	}
+	if err := enigma.WriteTraceHTML(f, fmt.Sprintf("Enigma trace of %q", msg), t.traces); err != nil {
+		f.Close()
+		glog.Fatalf("Could not write traces to %v: %s", traceHTMLFlag, err)
+	}
+	if err := f.Close(); err != nil {
+		glog.Fatalf("Could not write traces to %v: %s", traceHTMLFlag, err)
+	}
+	glog.Infof("Wrote the traces of %v keys to %v", len(t.traces), traceHTMLFlag)
+}
+
+// newTracePrinter returns a function that prints a trace in --traceFormat:
+// as a line of text (see formatTrace), or as a JSON object on a line of its
+// own, or as a Graphviz DOT graph.
+func newTracePrinter() func(*enigma.Trace) {
+	switch traceFormatFlag {
+	case "text":
+		labels := currentTraceLabels()
+		return func(trace *enigma.Trace) {
+			fmt.Println(formatTrace(trace, labels))
+		}
+	case "json":
+		return func(trace *enigma.Trace) {
+			line, err := json.Marshal(trace)
+			if err != nil {
+				glog.Fatalf("Could not write trace: %s", err)
+			}
+			fmt.Println(string(line))
+		}
+	case "dot":
+		return func(trace *enigma.Trace) {
+			if err := enigma.WriteTraceDOT(os.Stdout, trace); err != nil {
+				glog.Fatalf("Could not write trace: %s", err)
+			}
+		}
+	}
+	glog.Fatalf("--traceFormat must be 'text', 'json' or 'dot', got %q", traceFormatFlag)
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var plainFileFlag string
+var cipherFileFlag string
+var keySheetsFlag string
+
+// Letters are compared in groups of 5, 10 groups to a line, which is how
+// messages were written down on the message forms.
+const groupSize = 5
+const lettersPerLine = 10 * groupSize
+
+// readLetters reads the file at `path`, and returns its keys of `model`, with
+// all whitespace removed. Lowercase letters are read as the uppercase keys,
+// as the machine types them.
+func readLetters(path string, model enigma.Model) string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", path, err)
+	}
+	var letters strings.Builder
+	for _, c := range string(contents) {
+		if unicode.IsSpace(c) {
+			continue
+		}
+		key := c
+		if key > unicode.MaxASCII || !model.Alphabet.Contains(byte(key)) {
+			key = unicode.ToUpper(key)
+		}
+		if key > unicode.MaxASCII || !model.Alphabet.Contains(byte(key)) {
+			glog.Fatalf("File %v contains a character that is not a key of model %v: %q", path, modelFlag, c)
+		}
+		letters.WriteRune(key)
+	}
+	return letters.String()
+}
+
+// padTo extends `s` with '-' characters until it is `n` letters long.
+func padTo(s string, n int) string {
+	return s + strings.Repeat("-", n-len(s))
+}
+
+// inGroups splits `s` into space-separated groups of `groupSize` letters.
+func inGroups(s string) string {
+	var groups []string
+	for len(s) > groupSize {
+		groups = append(groups, s[:groupSize])
+		s = s[groupSize:]
+	}
+	return strings.Join(append(groups, s), " ")
+}
+
+// printMismatches lines up a plaintext, what it encrypts to and the
+// ciphertext it should encrypt to, and prints every line of them where the
+// latter two differ, marking each difference. If one text is longer than the
+// other, the missing letters in the shorter one count as mismatches. It
+// returns the number of mismatches, and the number of letters compared.
+func printMismatches(plain, expected, cipher string) (mismatches, n int) {
+	n = len(plain)
+	if len(cipher) > n {
+		n = len(cipher)
+	}
+	plain, expected, cipher = padTo(plain, n), padTo(expected, n), padTo(cipher, n)
+	for start := 0; start < n; start += lettersPerLine {
+		end := start + lettersPerLine
+		if end > n {
+			end = n
+		}
+		markers := make([]byte, end-start)
+		lineMismatches := 0
+		for i := start; i < end; i++ {
+			markers[i-start] = ' '
+			if expected[i] != cipher[i] {
+				markers[i-start] = '^'
+				lineMismatches++
+			}
+		}
+		if lineMismatches == 0 {
+			continue
+		}
+		mismatches += lineMismatches
+		fmt.Printf("%5d plain:    %s\n", start+1, inGroups(plain[start:end]))
+		fmt.Printf("      expected: %s\n", inGroups(expected[start:end]))
+		fmt.Printf("      cipher:   %s\n", inGroups(cipher[start:end]))
+		fmt.Printf("                %s\n", strings.TrimRight(inGroups(string(markers)), " "))
+	}
+	return mismatches, n
+}
+
+func verify(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	if plainFileFlag == "" || cipherFileFlag == "" {
+		glog.Fatalf("Both --plain and --cipher are required")
+	}
+	e := setUpEnigma(cmd)
+	model, err := parseModel(modelFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+
+	plain := readLetters(plainFileFlag, model)
+	cipher := readLetters(cipherFileFlag, model)
+	expected := enigma.Type(e, plain)
+	mismatches, n := printMismatches(plain, expected, cipher)
+
+	if len(plain) != len(cipher) {
+		fmt.Printf("Length mismatch: plaintext has %v letters, ciphertext has %v\n", len(plain), len(cipher))
+	}
+	if mismatches > 0 {
+		fmt.Printf("%v of %v letters do not match\n", mismatches, n)
+		os.Exit(1)
+	}
+	fmt.Printf("All %v letters match\n", n)
+}
+
+// recordName describes an archive record for humans: by its reference if it
+// has one, or otherwise by who sent it when.
+func recordName(line int, r enigma.ArchiveRecord) string {
+	if r.Reference != "" {
+		return r.Reference
+	}
+	return fmt.Sprintf("record %v (%v -> %v, %v)", line, r.From, r.To, r.Sent.Format("2006-01-02 15:04"))
+}
+
+func verifyArchive(cmd *cobra.Command, args []string) {
+	setUpLogging()
+	if keySheetsFlag == "" {
+		glog.Fatalf("--keySheets is required")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		glog.Fatalf("Could not read %v: %s", args[0], err)
+	}
+	records, err := enigma.ReadArchiveJSON(f)
+	f.Close()
+	if err != nil {
+		glog.Fatalf("%v: %s", args[0], err)
+	}
+
+	store := enigma.KeySheetDir{Dir: keySheetsFlag, Passphrase: readPassphrase()}
+	keySheets := make(map[string]enigma.KeySheet)
+	verified, skipped, failed := 0, 0, 0
+	for i, r := range records {
+		if !r.Verifiable() {
+			skipped++
+			continue
+		}
+		key, ok := keySheets[r.KeySheet]
+		if !ok {
+			if key, err = store.LoadKeySheet(r.KeySheet); err != nil {
+				glog.Fatalf("Could not load key sheet %v: %s", r.KeySheet, err)
+			}
+			keySheets[r.KeySheet] = key
+		}
+		verified++
+		expected, err := r.Reencrypt(key)
+		if err != nil {
+			fmt.Printf("%v: %s\n\n", recordName(i+1, r), err)
+			failed++
+			continue
+		}
+		if expected == r.Ciphertext {
+			continue
+		}
+		failed++
+		fmt.Printf("%v:\n", recordName(i+1, r))
+		mismatches, n := printMismatches(r.Plaintext, expected, r.Ciphertext)
+		fmt.Printf("%v of %v letters do not match\n\n", mismatches, n)
+	}
+
+	fmt.Printf("Verified %v of %v records (%v lacked a key or plaintext); %v did not match\n",
+		verified, len(records), skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !cipheronly
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rjhacks/enigma/enigma"
+	"github.com/spf13/cobra"
+)
+
+var gridFlag string
+var reportTimeFlag string
+var reportsFlag int
+
+func weather(cmd *cobra.Command, args []string) {
+	setUpLogging()
+
+	when, err := time.Parse("2006-01-02T15", reportTimeFlag)
+	if err != nil {
+		glog.Fatalf("Got invalid --time: %s", err)
+	}
+	crib, err := enigma.WeatherCrib(when, gridFlag)
+	if err != nil {
+		glog.Fatalf("%s", err)
+	}
+	seed := seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Crib: %v\n", crib)
+	fmt.Printf("Reports, generated with --seed=%v:\n", seed)
+	rnd := rand.New(rand.NewSource(seed))
+	for i := 0; i < reportsFlag; i++ {
+		report, err := enigma.GenerateWeatherReport(rnd, when, gridFlag)
+		if err != nil {
+			glog.Fatalf("%s", err)
+		}
+		short, err := enigma.EncodeWeatherReport(report)
+		if err != nil {
+			glog.Fatalf("%s", err)
+		}
+		fmt.Printf("%v  %v mb, %v°C, wind %v° force %v, visibility %v, cloud %v\n", short,
+			report.Pressure, report.Temperature, report.WindDirection*10, report.WindForce,
+			report.Visibility, report.Cloud)
+	}
+}